@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readConfig parses config, applies environment overrides and lists its
+// problems. Error is returned only if the file can't be read or parsed.
+func readConfig(path string) (config, []string, error) {
+	var c config
+	var root yaml.Node
+
+	yamlFile, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, nil, fmt.Errorf("Failed to read config: %v", err)
+	}
+	if err := yaml.Unmarshal(yamlFile, &root); err != nil {
+		return c, nil, fmt.Errorf("Failed to parse config: %v", err)
+	}
+	if err := root.Decode(&c); err != nil {
+		return c, nil, fmt.Errorf("Failed to parse config: %v", err)
+	}
+
+	problems := unknownFields(&root, reflect.TypeOf(c), "")
+	problems = append(problems, applyEnv(&c, os.LookupEnv)...)
+	problems = append(problems, c.problems()...)
+
+	return c, problems, nil
+}
+
+// unknownFields lists keys which don't match any field of the type. It
+// follows custom unmarshalers of Project and team by hand as yaml.v3
+// doesn't check fields decoded by them.
+func unknownFields(node *yaml.Node, t reflect.Type, path string) []string {
+	var problems []string
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.DocumentNode {
+		for _, child := range node.Content {
+			problems = append(problems, unknownFields(child, t, path)...)
+		}
+		return problems
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			if tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]; tag != "" && tag != "-" {
+				fields[tag] = t.Field(i).Type
+			}
+		}
+		if t == reflect.TypeOf(Project{}) {
+			fields["Teams"] = reflect.TypeOf(map[string]team{})
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, found := fields[key.Value]
+			if !found {
+				problems = append(problems, fmt.Sprintf("line %v: unknown field %v%v", key.Line, path, key.Value))
+				continue
+			}
+			problems = append(problems, unknownFields(value, field, path+key.Value+".")...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			problems = append(problems, unknownFields(value, t.Elem(), path+key.Value+".")...)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, item := range node.Content {
+			problems = append(problems, unknownFields(item, t.Elem(), fmt.Sprintf("%v%v.", path, i))...)
+		}
+	}
+
+	return problems
+}
+
+// problems lists mistakes which can be found without GitLab and LDAP
+func (c config) problems() []string {
+	var problems []string
+
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	awards := map[string]string{
+		"Like":         c.Awards.Like,
+		"Dislike":      c.Awards.Dislike,
+		"Ready":        c.Awards.Ready,
+		"NotReady":     c.Awards.NotReady,
+		"NonCompliant": c.Awards.NonCompliant,
+	}
+	seen := make(map[string]string)
+	for _, name := range []string{"Like", "Dislike", "Ready", "NotReady", "NonCompliant"} {
+		award := awards[name]
+		if award == "" {
+			report("Awards.%v is not set", name)
+			continue
+		}
+		if other, found := seen[award]; found {
+			report("Awards.%v and Awards.%v are both %v", other, name, award)
+		}
+		seen[award] = name
+	}
+
+	if u, err := url.Parse(c.Endpoints.GitLab); c.Endpoints.GitLab == "" {
+		report("Endpoints.GitLab is not set")
+	} else if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		report("Endpoints.GitLab %q is not http(s) URL", c.Endpoints.GitLab)
+	}
+	if err := checkEndpoint(c.Endpoints.DC.Host, c.Endpoints.DC.Port); err != nil {
+		report("Endpoints.DC: %v", err)
+	}
+	if err := checkEndpoint(c.Endpoints.SMTP.Host, c.Endpoints.SMTP.Port); err != nil {
+		report("Endpoints.SMTP: %v", err)
+	}
+
+	for _, source := range c.Approvals.Sources {
+		if !contains([]string{"emoji", "approvals", "notes"}, source) {
+			report("Approvals.Sources: unknown source %v", source)
+		}
+	}
+	approve, block := c.noteCommands()
+	if _, err := regexp.Compile(approve); err != nil {
+		report("Approvals.Approve: %v", err)
+	}
+	if _, err := regexp.Compile(block); err != nil {
+		report("Approvals.Block: %v", err)
+	}
+
+	for pid, project := range c.Projects {
+		if project == nil || len(project.Teams) == 0 {
+			report("Projects.%v has no teams", pid)
+			continue
+		}
+
+		for team, members := range project.Teams {
+			if _, found := project.TeamGroups[team]; !found && len(members) == 0 {
+				report("Projects.%v.Teams.%v has no members", pid, team)
+			}
+		}
+		for i, rule := range project.Paths {
+			for _, team := range rule.Teams {
+				if _, found := project.Teams[team]; !found {
+					report("Projects.%v.Paths.%v: unknown team %v", pid, i, team)
+				}
+			}
+		}
+		for i, policy := range project.Policies {
+			for _, team := range policy.Teams {
+				if _, found := project.Teams[team]; !found {
+					report("Projects.%v.Policies.%v: unknown team %v", pid, i, team)
+				}
+			}
+			if err := checkPattern(policy.Branch); err != nil {
+				report("Projects.%v.Policies.%v: %v", pid, i, err)
+			}
+		}
+		for i, pattern := range project.KeepBranches {
+			if err := checkPattern(pattern); err != nil {
+				report("Projects.%v.KeepBranches.%v: %v", pid, i, err)
+			}
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// checkEndpoint checks that host and port make a valid address
+func checkEndpoint(host string, port int) error {
+	if host == "" {
+		return fmt.Errorf("Host is not set")
+	}
+	if strings.Contains(host, "://") || strings.ContainsAny(host, " /") {
+		return fmt.Errorf("Host %q is not a host name", host)
+	}
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("Port %v is out of range", port)
+	}
+	if _, _, err := net.SplitHostPort(fmt.Sprintf("%v:%v", host, port)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkPattern checks glob or /regexp/ the way matchAny uses it
+func checkPattern(pattern string) error {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		_, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		return err
+	}
+	if pattern == "" {
+		return fmt.Errorf("empty pattern")
+	}
+	_, err := path.Match(pattern, "")
+	return err
+}
+
+// remoteProblems checks that projects, users and groups exist in GitLab
+func (c config) remoteProblems(git gitClient) []string {
+	var problems []string
+	users := make(map[string]bool)
+
+	for pid, project := range c.Projects {
+		if _, err := git.GetProject(pid); err != nil {
+			problems = append(problems, fmt.Sprintf("Projects.%v is not reachable: %v", pid, err))
+		}
+		if project == nil {
+			continue
+		}
+
+		for team, members := range project.Teams {
+			for _, user := range members {
+				exists, checked := users[user]
+				if !checked {
+					var err error
+					if exists, err = git.UserExists(user); err != nil {
+						problems = append(problems, fmt.Sprintf("Failed to check user %v: %v", user, err))
+						exists = true
+					}
+					users[user] = exists
+				}
+				if !exists {
+					problems = append(problems, fmt.Sprintf("Projects.%v.Teams.%v: unknown user %v", pid, team, user))
+				}
+			}
+		}
+
+		for team, group := range project.TeamGroups {
+			if strings.HasPrefix(group.Path, ldapPrefix) {
+				continue
+			}
+			if _, err := git.ListGroupMembers(group.Path); err != nil {
+				problems = append(problems, fmt.Sprintf("Projects.%v.Teams.%v: group %v is not reachable: %v",
+					pid, team, group.Path, err))
+			}
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// checkConfig prints all problems of the config, projects and users are
+// checked in GitLab if the config can be parsed. Exit code is returned.
+func checkConfig(path string, out io.Writer) int {
+	cfg, problems, err := readConfig(path)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+
+	if cfg.Endpoints.GitLab != "" {
+		git, err := newGitClient(cfg)
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			problems = append(problems, cfg.remoteProblems(git)...)
+		}
+	}
+
+	if len(problems) == 0 {
+		fmt.Fprintf(out, "%v is valid\n", path)
+		return 0
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(out, problem)
+	}
+	return 1
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestReadConfigExample(t *testing.T) {
+	_, problems, err := readConfig("config_example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("problems = %v", problems)
+	}
+}
+
+func TestReadConfigProblems(t *testing.T) {
+	data := strings.Replace(testConfigYAML, "  NotReady: x", "  NotReady: thumbsup", 1) + `
+    Votse: 2
+    Policies:
+      - Branch: /release-(/
+        Teams: [QA]
+        Vote: 1
+    Paths:
+      - Pattern: "*.go"
+        Teams: [Backend]
+  2:
+    Teams:
+      Backend:
+        Members: [alice]
+        Group: org/backend
+        Vots: 1
+`
+	want := []string{
+		"line 22: unknown field Projects.1.Votse",
+		"line 26: unknown field Projects.1.Policies.0.Vote",
+		"line 35: unknown field Projects.2.Teams.Backend.Vots",
+		"Awards.Like and Awards.NotReady are both thumbsup",
+		"Projects.1.Policies.0: error parsing regexp: missing closing ): `release-(`",
+		"Projects.1.Policies.0: unknown team QA",
+	}
+
+	_, problems, err := readConfig(writeConfig(t, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("problems:\n%v\nwant:\n%v", strings.Join(problems, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestConfigEndpoints(t *testing.T) {
+	tests := []struct {
+		host    string
+		port    int
+		wantErr bool
+	}{
+		{"smtp.example.com", 587, false},
+		{"10.0.0.1", 389, false},
+		{"", 389, true},
+		{"ldap://ad.example.com", 389, true},
+		{"ad.example.com", 0, true},
+		{"ad.example.com", 70000, true},
+	}
+
+	for _, tt := range tests {
+		if err := checkEndpoint(tt.host, tt.port); (err != nil) != tt.wantErr {
+			t.Errorf("checkEndpoint(%q, %v) = %v, wantErr %v", tt.host, tt.port, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRemoteProblems(t *testing.T) {
+	cfg := config{Projects: map[int]*Project{
+		1: {
+			Teams:      map[string][]string{"Backend": {"alice", "mallory"}, "Frontend": nil},
+			TeamGroups: map[string]teamGroup{"Frontend": {Path: "org/frontend"}},
+		},
+		2: {Teams: map[string][]string{"Backend": {"alice"}}},
+	}}
+	git := newFakeGit()
+	git.projects[1] = &gitlab.Project{ID: 1}
+	git.users = []string{"alice"}
+
+	want := []string{
+		"Projects.1.Teams.Backend: unknown user mallory",
+		"Projects.1.Teams.Frontend: group org/frontend is not reachable: 404 Group Not Found",
+		"Projects.2 is not reachable: 404 Not Found",
+	}
+	if got := cfg.remoteProblems(git); !reflect.DeepEqual(got, want) {
+		t.Errorf("problems = %v, want %v", got, want)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	var out bytes.Buffer
+
+	if code := checkConfig(writeConfig(t, "Projects: ["), &out); code != 1 {
+		t.Errorf("exit code = %v, want 1", code)
+	}
+	if !strings.Contains(out.String(), "Failed to parse config") {
+		t.Errorf("output = %q", out.String())
+	}
+}
@@ -1,19 +1,28 @@
 package main
 
 import (
-	"io/ioutil"
-	"log"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/xanzy/go-gitlab"
 	"gopkg.in/yaml.v3"
 )
 
 type config struct {
 	SMail string `yaml:"SMail"`
+	Store string `yaml:"Store"`
 
 	Credentials struct {
 		User     string `yaml:"User"`
 		Password string `yaml:"Password"`
 	} `yaml:"Credentials"`
+	GitLab struct {
+		Token      string `yaml:"Token"`
+		OAuthToken string `yaml:"OAuthToken"`
+		// GroupsCacheMinutes is how long members of group teams are cached
+		GroupsCacheMinutes int `yaml:"GroupsCacheMinutes"`
+	} `yaml:"GitLab"`
 	Endpoints struct {
 		DC struct {
 			Host   string `yaml:"Host"`
@@ -36,24 +45,288 @@ type config struct {
 		NotReady     string `yaml:"NotReady"`
 		NonCompliant string `yaml:"NonCompliant"`
 	} `yaml:"Awards"`
+	Approvals struct {
+		Sources []string `yaml:"Sources"`
+		Approve string   `yaml:"Approve"`
+		Block   string   `yaml:"Block"`
+	} `yaml:"Approvals"`
+	Branches struct {
+		DryRun          bool   `yaml:"DryRun"`
+		WarnAfterDays   int    `yaml:"WarnAfterDays"`
+		FinalAfterDays  int    `yaml:"FinalAfterDays"`
+		DeleteAfterDays int    `yaml:"DeleteAfterDays"`
+		KeepLabel       string `yaml:"KeepLabel"`
+	} `yaml:"Branches"`
+	Limits struct {
+		MergeRequests int `yaml:"MergeRequests"`
+	} `yaml:"Limits"`
+	Webhook struct {
+		Token string `yaml:"Token"`
+	} `yaml:"Webhook"`
 	Projects map[int]*Project `yaml:"Projects"`
 }
 
 type Project struct {
-	Teams map[string][]string `yaml:"Teams"`
-	Votes int                 `yaml:"Votes"`
+	// Teams, TeamVotes and TeamGroups are filled from Teams by UnmarshalYAML
+	Teams                 map[string][]string  `yaml:"-"`
+	TeamVotes             map[string]int       `yaml:"-"`
+	TeamGroups            map[string]teamGroup `yaml:"-"`
+	Votes                 int                  `yaml:"Votes"`
+	WarnAfterDays         int                  `yaml:"WarnAfterDays"`
+	FinalAfterDays        int                  `yaml:"FinalAfterDays"`
+	DeleteAfterDays       int                  `yaml:"DeleteAfterDays"`
+	DeleteMergedAfterDays int                  `yaml:"DeleteMergedAfterDays"`
+	KeepBranches          []string             `yaml:"KeepBranches"`
+	RemindAfterHours      int                  `yaml:"RemindAfterHours"`
+	EscalateAfterHours    int                  `yaml:"EscalateAfterHours"`
+	Paths                 []pathRule           `yaml:"Paths"`
+	CodeOwners            bool                 `yaml:"CodeOwners"`
+	Policies              []branchPolicy       `yaml:"Policies"`
+	ResetVotesOnPush      bool                 `yaml:"ResetVotesOnPush"`
+	Gates                 mrGates              `yaml:"Gates"`
+	StatusNote            bool                 `yaml:"StatusNote"`
 }
 
-func (c *config) getConfig() *config {
+// mrGates are conditions besides votes MR has to meet to be marked Ready
+type mrGates struct {
+	Pipeline    bool `yaml:"Pipeline"`
+	Discussions bool `yaml:"Discussions"`
+	Draft       bool `yaml:"Draft"`
+	Conflicts   bool `yaml:"Conflicts"`
+}
 
-	yamlFile, err := ioutil.ReadFile("config.yaml")
-	if err != nil {
-		log.Printf("yamlFile.Get err   #%v ", err)
+func (g mrGates) enabled() bool {
+	return g.Pipeline || g.Discussions || g.Draft || g.Conflicts
+}
+
+// branchPolicy narrows teams and votes required for MRs to target branches
+// matching glob or /regexp/
+type branchPolicy struct {
+	Branch string   `yaml:"Branch"`
+	Teams  []string `yaml:"Teams"`
+	Votes  int      `yaml:"Votes"`
+}
+
+// pathRule makes teams required reviewers of files matching the pattern
+type pathRule struct {
+	Pattern string   `yaml:"Pattern"`
+	Teams   []string `yaml:"Teams"`
+}
+
+// team is either a plain list of members, a group or a mapping with members,
+// group and its own number of required votes. Group is GitLab group path or
+// AD group DN prefixed with "ldap:".
+type team struct {
+	Members   []string `yaml:"Members"`
+	Group     string   `yaml:"Group"`
+	MinAccess string   `yaml:"MinAccess"`
+	Votes     int      `yaml:"Votes"`
+}
+
+// teamGroup is GitLab group whose members with at least MinAccess level
+// belong to the team
+type teamGroup struct {
+	Path      string
+	MinAccess gitlab.AccessLevelValue
+}
+
+// accessLevels are names of GitLab access levels accepted by MinAccess
+var accessLevels = map[string]gitlab.AccessLevelValue{
+	"guest":      gitlab.GuestPermissions,
+	"reporter":   gitlab.ReporterPermissions,
+	"developer":  gitlab.DeveloperPermissions,
+	"maintainer": gitlab.MaintainerPermissions,
+	"owner":      gitlab.OwnerPermissions,
+}
+
+func (t *team) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.SequenceNode:
+		return value.Decode(&t.Members)
+	case yaml.ScalarNode:
+		return value.Decode(&t.Group)
+	}
+
+	type plain team
+	return value.Decode((*plain)(t))
+}
+
+func (p *Project) UnmarshalYAML(value *yaml.Node) error {
+	type plain Project
+	var teams struct {
+		Teams map[string]team `yaml:"Teams"`
+	}
+
+	if err := value.Decode((*plain)(p)); err != nil {
+		return err
+	}
+	if err := value.Decode(&teams); err != nil {
+		return err
+	}
+
+	p.Teams = make(map[string][]string)
+	p.TeamVotes = make(map[string]int)
+	p.TeamGroups = make(map[string]teamGroup)
+	for name, t := range teams.Teams {
+		p.Teams[name] = t.Members
+		if t.Votes > 0 {
+			p.TeamVotes[name] = t.Votes
+		}
+
+		if t.Group != "" {
+			group := teamGroup{Path: t.Group}
+			if t.MinAccess != "" && strings.HasPrefix(t.Group, ldapPrefix) {
+				return fmt.Errorf("MinAccess of team %v does not apply to LDAP group", name)
+			}
+			if t.MinAccess != "" {
+				level, found := accessLevels[strings.ToLower(t.MinAccess)]
+				if !found {
+					return fmt.Errorf("Unknown access level %v of team %v", t.MinAccess, name)
+				}
+				group.MinAccess = level
+			}
+			p.TeamGroups[name] = group
+		}
+	}
+
+	return nil
+}
+
+// forBranch applies the first policy matching target branch. Policy Votes
+// is required from every team, overriding team and project settings.
+func (p *Project) forBranch(branch string) *Project {
+	if policy := p.policyFor(branch); policy != nil {
+		scoped := *p
+		if policy.Votes > 0 {
+			scoped.Votes = policy.Votes
+			scoped.TeamVotes = nil
+		}
+		if len(policy.Teams) > 0 {
+			scoped.Teams = make(map[string][]string)
+			for _, team := range policy.Teams {
+				if members, found := p.Teams[team]; found {
+					scoped.Teams[team] = members
+				}
+			}
+		}
+
+		return &scoped
+	}
+
+	return p
+}
+
+// policyFor returns the first policy matching target branch or nil
+func (p *Project) policyFor(branch string) *branchPolicy {
+	for i, policy := range p.Policies {
+		if matchAny([]string{policy.Branch}, branch) {
+			return &p.Policies[i]
+		}
 	}
-	err = yaml.Unmarshal(yamlFile, c)
+
+	return nil
+}
+
+// consensus returns number of likes required from the team: its own
+// setting, then project Votes, then 2 for a single team and 1 otherwise
+func (p *Project) consensus(team string) int {
+	if votes := p.TeamVotes[team]; votes > 0 {
+		return votes
+	}
+	if p.Votes > 0 {
+		return p.Votes
+	}
+	if len(p.Teams) < 2 {
+		return 2
+	}
+	return 1
+}
+
+// branchTiers holds days without updates for stale branch notifications
+// and deletion. Zero disables the tier.
+type branchTiers struct {
+	Warn   int
+	Final  int
+	Delete int
+}
+
+// tiers returns project tiers falling back to global ones, authors are
+// warned after 7 days by default
+func (c config) tiers(pid int) branchTiers {
+	tiers := branchTiers{
+		Warn:   c.Branches.WarnAfterDays,
+		Final:  c.Branches.FinalAfterDays,
+		Delete: c.Branches.DeleteAfterDays,
+	}
+	if tiers.Warn == 0 {
+		tiers.Warn = 7
+	}
+
+	if project, found := c.Projects[pid]; found {
+		if project.WarnAfterDays > 0 {
+			tiers.Warn = project.WarnAfterDays
+		}
+		if project.FinalAfterDays > 0 {
+			tiers.Final = project.FinalAfterDays
+		}
+		if project.DeleteAfterDays > 0 {
+			tiers.Delete = project.DeleteAfterDays
+		}
+	}
+
+	return tiers
+}
+
+// approvalSources returns where votes are taken from: emoji, approvals
+// or notes, only emoji are used by default
+func (c config) approvalSources() []string {
+	if len(c.Approvals.Sources) > 0 {
+		return c.Approvals.Sources
+	}
+	return []string{"emoji"}
+}
+
+// noteCommands returns patterns of notes which approve or block MR
+func (c config) noteCommands() (string, string) {
+	approve, block := c.Approvals.Approve, c.Approvals.Block
+	if approve == "" {
+		approve = `^/lgtm\b`
+	}
+	if block == "" {
+		block = `^/block\b`
+	}
+	return approve, block
+}
+
+// groupsCache returns for how long members of group teams are cached
+func (c config) groupsCache() time.Duration {
+	if c.GitLab.GroupsCacheMinutes > 0 {
+		return time.Duration(c.GitLab.GroupsCacheMinutes) * time.Minute
+	}
+	return time.Hour
+}
+
+// keepLabel returns label of open MRs which exempts their source branches
+func (c config) keepLabel() string {
+	if c.Branches.KeepLabel != "" {
+		return c.Branches.KeepLabel
+	}
+	return "keep-branch"
+}
+
+// configFile is the path config is loaded from
+var configFile = "config.yaml"
+
+// loadConfig reads and validates config, an error is returned on any
+// problem so a broken file never replaces a working config
+func loadConfig(path string) (config, error) {
+	c, problems, err := readConfig(path)
 	if err != nil {
-		log.Fatalf("Unmarshal: %v", err)
+		return c, err
+	}
+	if len(problems) > 0 {
+		return c, fmt.Errorf("Invalid config: %v", strings.Join(problems, "; "))
 	}
 
-	return c
+	return c, nil
 }
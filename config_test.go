@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestProjectTeams(t *testing.T) {
+	data := `
+Projects:
+  1:
+    Votes: 2
+    Teams:
+      Backend:
+        - alice
+        - bob
+      Security:
+        Votes: 1
+        Members:
+          - carol
+`
+	var cfg config
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	project := cfg.Projects[1]
+
+	wantTeams := map[string][]string{"Backend": {"alice", "bob"}, "Security": {"carol"}}
+	if !reflect.DeepEqual(project.Teams, wantTeams) {
+		t.Errorf("Teams = %v, want %v", project.Teams, wantTeams)
+	}
+	if got := project.consensus("Backend"); got != 2 {
+		t.Errorf("Backend consensus = %v, want 2", got)
+	}
+	if got := project.consensus("Security"); got != 1 {
+		t.Errorf("Security consensus = %v, want 1", got)
+	}
+}
+
+func TestProjectConsensus(t *testing.T) {
+	tests := []struct {
+		name    string
+		project Project
+		want    int
+	}{
+		{"single team", Project{Teams: map[string][]string{"Backend": nil}}, 2},
+		{"multiple teams", Project{Teams: map[string][]string{"Backend": nil, "Frontend": nil}}, 1},
+		{"project votes", Project{Teams: map[string][]string{"Backend": nil}, Votes: 3}, 3},
+		{
+			"team votes",
+			Project{Teams: map[string][]string{"Backend": nil}, Votes: 3, TeamVotes: map[string]int{"Backend": 1}},
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.project.consensus("Backend"); got != tt.want {
+				t.Errorf("consensus = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envPrefix starts names of variables overriding config fields, e.g.
+// WARD_ENDPOINTS_SMTP_PASSWORD for Endpoints.SMTP.Password
+const envPrefix = "WARD"
+
+// applyEnv overrides config fields with environment variables. NAME_FILE
+// variant reads the value from a file, e.g. a mounted secret. Projects are
+// configured by the file only.
+func applyEnv(c *config, lookup func(string) (string, bool)) []string {
+	return applyEnvFields(reflect.ValueOf(c).Elem(), envPrefix, lookup)
+}
+
+func applyEnvFields(v reflect.Value, prefix string, lookup func(string) (string, bool)) []string {
+	var problems []string
+
+	for i := 0; i < v.NumField(); i++ {
+		tag := strings.Split(v.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := fmt.Sprintf("%v_%v", prefix, strings.ToUpper(tag))
+		field := v.Field(i)
+
+		if field.Kind() == reflect.Struct {
+			problems = append(problems, applyEnvFields(field, name, lookup)...)
+			continue
+		}
+
+		value, found, err := envValue(name, lookup)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if !found {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Int:
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%v is not a number: %v", name, value))
+				continue
+			}
+			field.SetInt(int64(number))
+		case reflect.Bool:
+			flag, err := strconv.ParseBool(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%v is not a boolean: %v", name, value))
+				continue
+			}
+			field.SetBool(flag)
+		case reflect.Slice:
+			if field.Type().Elem().Kind() != reflect.String {
+				continue
+			}
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+		}
+	}
+
+	return problems
+}
+
+// envValue returns the variable or content of the file named by NAME_FILE
+func envValue(name string, lookup func(string) (string, bool)) (string, bool, error) {
+	value, found := lookup(name)
+	file, fromFile := lookup(name + "_FILE")
+
+	switch {
+	case found && fromFile:
+		return "", false, fmt.Errorf("Both %v and %v_FILE are set", name, name)
+	case fromFile:
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", false, fmt.Errorf("Failed to read %v_FILE: %v", name, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+
+	return value, found, nil
+}
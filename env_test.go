@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "password")
+	if err := ioutil.WriteFile(secret, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"WARD_CREDENTIALS_PASSWORD_FILE": secret,
+		"WARD_ENDPOINTS_SMTP_PORT":       "2525",
+		"WARD_BRANCHES_DRYRUN":           "true",
+		"WARD_APPROVALS_SOURCES":         "emoji, notes",
+		"WARD_GITLAB_TOKEN":              "glpat-env",
+	}
+	lookup := func(name string) (string, bool) {
+		value, found := env[name]
+		return value, found
+	}
+
+	var c config
+	c.GitLab.Token = "glpat-file"
+	c.Webhook.Token = "hook"
+
+	if problems := applyEnv(&c, lookup); len(problems) > 0 {
+		t.Fatalf("problems = %v", problems)
+	}
+	if c.Credentials.Password != "s3cret" {
+		t.Errorf("Credentials.Password = %q", c.Credentials.Password)
+	}
+	if c.Endpoints.SMTP.Port != 2525 {
+		t.Errorf("Endpoints.SMTP.Port = %v", c.Endpoints.SMTP.Port)
+	}
+	if !c.Branches.DryRun {
+		t.Errorf("Branches.DryRun is not set")
+	}
+	if !reflect.DeepEqual(c.Approvals.Sources, []string{"emoji", "notes"}) {
+		t.Errorf("Approvals.Sources = %v", c.Approvals.Sources)
+	}
+	if c.GitLab.Token != "glpat-env" || c.Webhook.Token != "hook" {
+		t.Errorf("GitLab.Token = %v, Webhook.Token = %v", c.GitLab.Token, c.Webhook.Token)
+	}
+}
+
+func TestApplyEnvProblems(t *testing.T) {
+	env := map[string]string{
+		"WARD_SMAIL":              "bot@example.com",
+		"WARD_SMAIL_FILE":         "/run/secrets/smail",
+		"WARD_ENDPOINTS_DC_PORT":  "ldap",
+		"WARD_BRANCHES_DRYRUN":    "maybe",
+		"WARD_WEBHOOK_TOKEN_FILE": filepath.Join(t.TempDir(), "missing"),
+	}
+	lookup := func(name string) (string, bool) {
+		value, found := env[name]
+		return value, found
+	}
+
+	var c config
+	problems := applyEnv(&c, lookup)
+	if len(problems) != 4 {
+		t.Errorf("problems = %v", problems)
+	}
+	if c.SMail != "" || c.Endpoints.DC.Port != 0 {
+		t.Errorf("invalid values are applied: %v, %v", c.SMail, c.Endpoints.DC.Port)
+	}
+}
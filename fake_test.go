@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// fakeGit is an in-memory gitClient for tests
+type fakeGit struct {
+	username  string
+	nextID    int
+	mrs       map[int][]*gitlab.MergeRequest
+	awards    map[int]map[int][]*gitlab.AwardEmoji
+	branches  map[int][]*gitlab.Branch
+	protected map[int][]string
+	projects  map[int]*gitlab.Project
+	notes     map[int]map[int][]*gitlab.Note
+	deleted   map[int][]string
+	tags      map[int]map[string]string
+	changes   map[int]map[int][]string
+	files     map[int]map[string]string
+	versions  map[int]map[int][]*gitlab.MergeRequestDiffVersion
+	approvers map[int]map[int][]string
+	pipelines map[int]map[int]*gitlab.PipelineInfo
+	threads   map[int]map[int]int
+	groups    map[string][]*gitlab.GroupMember
+	// groupCalls counts requests of group members
+	groupCalls int
+	users      []string
+	// awardErr fails creation of awards
+	awardErr error
+	// broken MRs fail every request about them
+	broken map[int]bool
+}
+
+func newFakeGit() *fakeGit {
+	return &fakeGit{
+		username:  "ward",
+		mrs:       make(map[int][]*gitlab.MergeRequest),
+		awards:    make(map[int]map[int][]*gitlab.AwardEmoji),
+		branches:  make(map[int][]*gitlab.Branch),
+		protected: make(map[int][]string),
+		projects:  make(map[int]*gitlab.Project),
+		notes:     make(map[int]map[int][]*gitlab.Note),
+		deleted:   make(map[int][]string),
+		tags:      make(map[int]map[string]string),
+		changes:   make(map[int]map[int][]string),
+		files:     make(map[int]map[string]string),
+		versions:  make(map[int]map[int][]*gitlab.MergeRequestDiffVersion),
+		approvers: make(map[int]map[int][]string),
+		pipelines: make(map[int]map[int]*gitlab.PipelineInfo),
+		threads:   make(map[int]map[int]int),
+		groups:    make(map[string][]*gitlab.GroupMember),
+		broken:    make(map[int]bool),
+	}
+}
+
+func (f *fakeGit) addAward(pid int, mid int, user string, name string) *gitlab.AwardEmoji {
+	f.nextID++
+
+	award := &gitlab.AwardEmoji{ID: f.nextID, Name: name}
+	award.User.Username = user
+
+	if f.awards[pid] == nil {
+		f.awards[pid] = make(map[int][]*gitlab.AwardEmoji)
+	}
+	f.awards[pid][mid] = append(f.awards[pid][mid], award)
+
+	return award
+}
+
+func (f *fakeGit) addNote(pid int, mid int, user string, body string, created time.Time) *gitlab.Note {
+	f.nextID++
+
+	note := &gitlab.Note{ID: f.nextID, Body: body, CreatedAt: gitlab.Time(created)}
+	note.Author.Username = user
+
+	if f.notes[pid] == nil {
+		f.notes[pid] = make(map[int][]*gitlab.Note)
+	}
+	f.notes[pid][mid] = append(f.notes[pid][mid], note)
+
+	return note
+}
+
+// page returns bounds of the requested page and the next page number
+func page(total int, opts gitlab.ListOptions) (int, int, int) {
+	perPage := opts.PerPage
+	if perPage == 0 {
+		perPage = 20
+	}
+	current := opts.Page
+	if current == 0 {
+		current = 1
+	}
+
+	start := (current - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end >= total {
+		return start, total, 0
+	}
+	return start, end, current + 1
+}
+
+func (f *fakeGit) ListMergeRequests(pid int, opts *gitlab.ListProjectMergeRequestsOptions) ([]*gitlab.MergeRequest, int, error) {
+	var mrs []*gitlab.MergeRequest
+
+	for _, mr := range f.mrs[pid] {
+		if opts.State != nil && *opts.State != mr.State {
+			continue
+		}
+		if opts.SourceBranch != nil && *opts.SourceBranch != mr.SourceBranch {
+			continue
+		}
+		if opts.UpdatedAfter != nil && mr.UpdatedAt != nil && !mr.UpdatedAt.After(*opts.UpdatedAfter) {
+			continue
+		}
+		mrs = append(mrs, mr)
+	}
+
+	start, end, next := page(len(mrs), opts.ListOptions)
+	return mrs[start:end], next, nil
+}
+
+func (f *fakeGit) GetMergeRequest(pid int, mid int) (*gitlab.MergeRequest, error) {
+	for _, mr := range f.mrs[pid] {
+		if mr.IID == mid {
+			return mr, nil
+		}
+	}
+	return nil, fmt.Errorf("404 Not Found")
+}
+
+func (f *fakeGit) ListAwards(pid int, mid int) ([]*gitlab.AwardEmoji, error) {
+	if f.broken[mid] {
+		return nil, fmt.Errorf("500 Internal Server Error")
+	}
+	return f.awards[pid][mid], nil
+}
+
+func (f *fakeGit) Username() string {
+	return f.username
+}
+
+func (f *fakeGit) CreateAward(pid int, mid int, name string) error {
+	if f.awardErr != nil {
+		return f.awardErr
+	}
+	f.addAward(pid, mid, f.username, name)
+	return nil
+}
+
+func (f *fakeGit) DeleteAward(pid int, mid int, aid int) error {
+	awards := f.awards[pid][mid]
+	for i, award := range awards {
+		if award.ID == aid {
+			f.awards[pid][mid] = append(awards[:i], awards[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("404 Not Found")
+}
+
+func (f *fakeGit) ListBranches(pid int, opts *gitlab.ListBranchesOptions) ([]*gitlab.Branch, int, error) {
+	branches := f.branches[pid]
+
+	start, end, next := page(len(branches), opts.ListOptions)
+	return branches[start:end], next, nil
+}
+
+func (f *fakeGit) ListProtectedBranches(pid int) ([]string, error) {
+	return f.protected[pid], nil
+}
+
+func (f *fakeGit) GetProject(pid int) (*gitlab.Project, error) {
+	if prj, found := f.projects[pid]; found {
+		return prj, nil
+	}
+	return nil, fmt.Errorf("404 Not Found")
+}
+
+func (f *fakeGit) ListNotes(pid int, mid int) ([]*gitlab.Note, error) {
+	return f.notes[pid][mid], nil
+}
+
+func (f *fakeGit) CreateNote(pid int, mid int, body string) error {
+	f.addNote(pid, mid, f.username, body, time.Now())
+	return nil
+}
+
+func (f *fakeGit) DeleteBranch(pid int, branch string) error {
+	branches := f.branches[pid]
+	for i, b := range branches {
+		if b.Name == branch {
+			f.branches[pid] = append(branches[:i], branches[i+1:]...)
+			f.deleted[pid] = append(f.deleted[pid], branch)
+			return nil
+		}
+	}
+	return fmt.Errorf("404 Branch Not Found")
+}
+
+func (f *fakeGit) CreateTag(pid int, tag string, ref string) error {
+	if f.tags[pid] == nil {
+		f.tags[pid] = make(map[string]string)
+	}
+	if _, found := f.tags[pid][tag]; found {
+		return fmt.Errorf("400 Tag %v already exists", tag)
+	}
+	f.tags[pid][tag] = ref
+	return nil
+}
+
+func (f *fakeGit) ListChanges(pid int, mid int) ([]string, error) {
+	return f.changes[pid][mid], nil
+}
+
+// GetRawFile looks files up by "ref:path"
+func (f *fakeGit) GetRawFile(pid int, file string, ref string) ([]byte, error) {
+	if data, found := f.files[pid][ref+":"+file]; found {
+		return []byte(data), nil
+	}
+	return nil, fmt.Errorf("404 File Not Found")
+}
+
+// LatestVersion returns the first version as GitLab lists the newest first
+func (f *fakeGit) LatestVersion(pid int, mid int) (*gitlab.MergeRequestDiffVersion, error) {
+	if versions := f.versions[pid][mid]; len(versions) > 0 {
+		return versions[0], nil
+	}
+	return nil, nil
+}
+
+func (f *fakeGit) ListApprovers(pid int, mid int) ([]string, error) {
+	return f.approvers[pid][mid], nil
+}
+
+func (f *fakeGit) LatestPipeline(pid int, mid int) (*gitlab.PipelineInfo, error) {
+	if f.broken[mid] {
+		return nil, fmt.Errorf("500 Internal Server Error")
+	}
+	return f.pipelines[pid][mid], nil
+}
+
+// UnresolvedDiscussions returns the number of unresolved threads
+func (f *fakeGit) UnresolvedDiscussions(pid int, mid int) (int, error) {
+	if f.broken[mid] {
+		return 0, fmt.Errorf("500 Internal Server Error")
+	}
+	return f.threads[pid][mid], nil
+}
+
+func (f *fakeGit) UpdateNote(pid int, mid int, nid int, body string) error {
+	for _, note := range f.notes[pid][mid] {
+		if note.ID == nid {
+			note.Body = body
+			return nil
+		}
+	}
+	return fmt.Errorf("404 Note Not Found")
+}
+
+func (f *fakeGit) ListGroupMembers(group string) ([]*gitlab.GroupMember, error) {
+	f.groupCalls++
+	if members, found := f.groups[group]; found {
+		return members, nil
+	}
+	return nil, fmt.Errorf("404 Group Not Found")
+}
+
+func (f *fakeGit) UserExists(username string) (bool, error) {
+	return contains(f.users, username), nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// checkGates returns descriptions of enabled gates MR doesn't pass. A gate
+// which can't be checked is not passed.
+func checkGates(git gitClient, project *Project, pid int, mr *gitlab.MergeRequest) []string {
+	var unmet []string
+
+	if project.Gates.Draft && mr.WorkInProgress {
+		unmet = append(unmet, "MR is a draft")
+	}
+
+	if project.Gates.Conflicts && mr.MergeStatus == "cannot_be_merged" {
+		unmet = append(unmet, "MR has merge conflicts")
+	}
+
+	if project.Gates.Pipeline {
+		pipeline, err := git.LatestPipeline(pid, mr.IID)
+		switch {
+		case err != nil:
+			log.Printf("Failed to get pipeline of %v@%v: %v", mr.IID, pid, err)
+			unmet = append(unmet, "pipeline status is unknown")
+		case pipeline == nil:
+			unmet = append(unmet, "pipeline has not run")
+		case pipeline.Status != "success":
+			unmet = append(unmet, fmt.Sprintf("pipeline is %v", pipeline.Status))
+		}
+	}
+
+	if project.Gates.Discussions {
+		unresolved, err := git.UnresolvedDiscussions(pid, mr.IID)
+		switch {
+		case err != nil:
+			log.Printf("Failed to list discussions of %v@%v: %v", mr.IID, pid, err)
+			unmet = append(unmet, "discussions are not checked")
+		case unresolved == 1:
+			unmet = append(unmet, "1 discussion is unresolved")
+		case unresolved > 1:
+			unmet = append(unmet, fmt.Sprintf("%v discussions are unresolved", unresolved))
+		}
+	}
+
+	return unmet
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestCheckGates(t *testing.T) {
+	all := mrGates{Pipeline: true, Discussions: true, Draft: true, Conflicts: true}
+
+	tests := []struct {
+		name     string
+		gates    mrGates
+		draft    bool
+		status   string
+		pipeline *gitlab.PipelineInfo
+		threads  int
+		want     []string
+	}{
+		{"gates are disabled", mrGates{}, true, "cannot_be_merged", nil, 2, nil},
+		{"all gates pass", all, false, "can_be_merged", &gitlab.PipelineInfo{Status: "success"}, 0, nil},
+		{
+			"all gates fail", all, true, "cannot_be_merged", &gitlab.PipelineInfo{Status: "failed"}, 2,
+			[]string{"MR is a draft", "MR has merge conflicts", "pipeline is failed", "2 discussions are unresolved"},
+		},
+		{"pipeline has not run", mrGates{Pipeline: true}, false, "", nil, 0, []string{"pipeline has not run"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			git := newFakeGit()
+			mr := testMR(1, "opened", "master")
+			mr.WorkInProgress = tt.draft
+			mr.MergeStatus = tt.status
+			git.pipelines[1] = map[int]*gitlab.PipelineInfo{1: tt.pipeline}
+			git.threads[1] = map[int]int{1: tt.threads}
+
+			got := checkGates(git, &Project{Gates: tt.gates}, 1, mr)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unmet = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalOpenedRequestsGates(t *testing.T) {
+	mrs := testRequest(true, false, 0, 0, 0)
+	mr := mrs[1].MR[1]
+	mr.Unmet = []string{"pipeline is failed"}
+	mrs[1].MR[1] = mr
+
+	got := actionNames(evalOpenedRequests(mrs))
+	want := []string{"notready:true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("actions = %v, want %v", got, want)
+	}
+}
+
+func TestCheckGatesFailed(t *testing.T) {
+	git := newFakeGit()
+	git.broken[1] = true
+	mr := testMR(1, "opened", "master")
+
+	got := checkGates(git, &Project{Gates: mrGates{Pipeline: true, Discussions: true}}, 1, mr)
+	want := []string{"pipeline status is unknown", "discussions are not checked"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unmet = %v, want %v", got, want)
+	}
+}
+
+func TestListRequestsBrokenMR(t *testing.T) {
+	cfg := testConfig()
+	git := newFakeGit()
+	projects := map[int]*Project{1: {Teams: map[string][]string{"Backend": {"alice"}}}}
+	start := time.Now().Add(-time.Hour)
+
+	git.protected[1] = []string{"master"}
+	for i := 1; i <= 3; i++ {
+		mr := testMR(i, "merged", "master")
+		mr.UpdatedAt = gitlab.Time(start.Add(time.Duration(i) * time.Minute))
+		git.mrs[1] = append(git.mrs[1], mr)
+	}
+	git.broken[2] = true
+
+	mrs, err := checkPrjRequests(cfg, git, projects, "merged", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mrs[1].MR) != 2 {
+		t.Errorf("%v MRs checked, want 2: %v", len(mrs[1].MR), mrs[1].MR)
+	}
+	// Watermark must not pass the MR which failed
+	if want := start.Add(time.Minute); !mrs[1].Updated.Equal(want) {
+		t.Errorf("Updated = %v, want %v", mrs[1].Updated, want)
+	}
+}
+
+func TestDraftNotification(t *testing.T) {
+	useStore(t)
+	cfg := testConfig()
+	cfg.Projects = map[int]*Project{1: {Teams: map[string][]string{"Backend": {"alice"}}, Gates: mrGates{Draft: true}}}
+	git := newFakeGit()
+	votes := mrVotes{Missing: map[string]int{"Backend": 1}}
+
+	draft := MergeRequest{Draft: true, Unmet: []string{"MR is a draft"}, Votes: votes}
+	mrs := map[int]MrProject{1: {MR: map[int]MergeRequest{1: draft}}}
+	processMR(cfg, git, evalOpenedRequests(mrs))
+	notifyUndrafted(cfg, git, mrs)
+	if notes := git.notes[1][1]; len(notes) != 0 {
+		t.Fatalf("reviewers were pinged on draft: %v", notes)
+	}
+
+	undrafted := MergeRequest{Votes: votes}
+	undrafted.Awards.NotReady = git.awards[1][1][0].ID
+	mrs = map[int]MrProject{1: {MR: map[int]MergeRequest{1: undrafted}}}
+	processMR(cfg, git, evalOpenedRequests(mrs))
+	notifyUndrafted(cfg, git, mrs)
+	notifyUndrafted(cfg, git, mrs)
+	if notes := git.notes[1][1]; len(notes) != 1 {
+		t.Errorf("reviewers were not pinged once after draft: %v", notes)
+	}
+}
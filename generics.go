@@ -1,5 +1,11 @@
 package main
 
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
 func contains(arr []string, str string) bool {
 	for _, a := range arr {
 		if a == str {
@@ -8,3 +14,20 @@ func contains(arr []string, str string) bool {
 	}
 	return false
 }
+
+// matchAny reports if str matches any of patterns. Patterns are globs
+// unless enclosed in slashes like /^hotfix-[0-9]+$/ for regexps.
+func matchAny(patterns []string, str string) bool {
+	for _, pattern := range patterns {
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			if ok, _ := regexp.MatchString(pattern[1:len(pattern)-1], str); ok {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, str); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestMatchAny(t *testing.T) {
+	patterns := []string{"release/*", "hotfix-*", "/^feature-[0-9]+$/", "/[/"}
+
+	tests := []struct {
+		str  string
+		want bool
+	}{
+		{"release/1.0", true},
+		{"release/1.0/fix", false},
+		{"hotfix-login", true},
+		{"feature-42", true},
+		{"feature-42a", false},
+		{"master", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchAny(patterns, tt.str); got != tt.want {
+			t.Errorf("matchAny(%q) = %v, want %v", tt.str, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// gitClient is the part of GitLab API used by ward. List methods return
+// the next page to request or 0 when there are no more pages.
+type gitClient interface {
+	ListMergeRequests(pid int, opts *gitlab.ListProjectMergeRequestsOptions) ([]*gitlab.MergeRequest, int, error)
+	GetMergeRequest(pid int, mid int) (*gitlab.MergeRequest, error)
+	ListAwards(pid int, mid int) ([]*gitlab.AwardEmoji, error)
+	CreateAward(pid int, mid int, name string) error
+	DeleteAward(pid int, mid int, aid int) error
+	ListBranches(pid int, opts *gitlab.ListBranchesOptions) ([]*gitlab.Branch, int, error)
+	ListProtectedBranches(pid int) ([]string, error)
+	GetProject(pid int) (*gitlab.Project, error)
+	ListNotes(pid int, mid int) ([]*gitlab.Note, error)
+	CreateNote(pid int, mid int, body string) error
+	UpdateNote(pid int, mid int, nid int, body string) error
+	DeleteBranch(pid int, branch string) error
+	CreateTag(pid int, tag string, ref string) error
+	// ListChanges returns old and new paths of files changed by MR
+	ListChanges(pid int, mid int) ([]string, error)
+	GetRawFile(pid int, file string, ref string) ([]byte, error)
+	// ListApprovers returns usernames of users who approved MR
+	ListApprovers(pid int, mid int) ([]string, error)
+	// LatestPipeline returns the latest pipeline of MR or nil
+	LatestPipeline(pid int, mid int) (*gitlab.PipelineInfo, error)
+	UnresolvedDiscussions(pid int, mid int) (int, error)
+	// ListGroupMembers returns members of the group by its path including
+	// ones inherited from parent groups
+	ListGroupMembers(group string) ([]*gitlab.GroupMember, error)
+	// LatestVersion returns the latest diff version of MR or nil
+	LatestVersion(pid int, mid int) (*gitlab.MergeRequestDiffVersion, error)
+	UserExists(username string) (bool, error)
+	// Username is the account ward acts as, its awards are service ones
+	Username() string
+}
+
+// gitlabClient implements gitClient with go-gitlab
+type gitlabClient struct {
+	git      *gitlab.Client
+	username string
+}
+
+// usernames caches the account behind credentials, keyed by their hash, so
+// it is not requested by every job and webhook event
+var usernames = struct {
+	sync.Mutex
+	names map[string]string
+}{names: make(map[string]string)}
+
+// newGitClient prefers access token, then OAuth token and falls back to
+// basic auth with the domain credentials.
+func newGitClient(cfg config) (gitClient, error) {
+	var git *gitlab.Client
+	var err error
+	var credentials string
+
+	git_opts := gitlab.WithBaseURL(cfg.Endpoints.GitLab)
+	switch {
+	case cfg.GitLab.Token != "":
+		git, err = gitlab.NewClient(cfg.GitLab.Token, git_opts)
+		credentials = "token:" + cfg.GitLab.Token
+	case cfg.GitLab.OAuthToken != "":
+		git, err = gitlab.NewOAuthClient(cfg.GitLab.OAuthToken, git_opts)
+		credentials = "oauth:" + cfg.GitLab.OAuthToken
+	default:
+		git, err = gitlab.NewBasicAuthClient(
+			cfg.Credentials.User, cfg.Credentials.Password, git_opts)
+		credentials = "basic:" + cfg.Credentials.User + ":" + cfg.Credentials.Password
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to GitLab: %v", err)
+	}
+
+	key := fmt.Sprintf("%x", sha256.Sum256([]byte(cfg.Endpoints.GitLab+"\n"+credentials)))
+
+	usernames.Lock()
+	defer usernames.Unlock()
+
+	if username, found := usernames.names[key]; found {
+		return &gitlabClient{git: git, username: username}, nil
+	}
+
+	user, _, err := git.Users.CurrentUser()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get GitLab user: %v", err)
+	}
+	usernames.names[key] = strings.ToLower(user.Username)
+
+	return &gitlabClient{git: git, username: usernames.names[key]}, nil
+}
+
+func (c *gitlabClient) Username() string {
+	return c.username
+}
+
+func (c *gitlabClient) ListMergeRequests(pid int, opts *gitlab.ListProjectMergeRequestsOptions) ([]*gitlab.MergeRequest, int, error) {
+	mrs, response, err := c.git.MergeRequests.ListProjectMergeRequests(pid, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return mrs, response.NextPage, nil
+}
+
+func (c *gitlabClient) GetMergeRequest(pid int, mid int) (*gitlab.MergeRequest, error) {
+	mr, _, err := c.git.MergeRequests.GetMergeRequest(pid, mid, &gitlab.GetMergeRequestsOptions{})
+	return mr, err
+}
+
+func (c *gitlabClient) ListAwards(pid int, mid int) ([]*gitlab.AwardEmoji, error) {
+	var awards []*gitlab.AwardEmoji
+
+	opts := &gitlab.ListAwardEmojiOptions{Page: 1, PerPage: 100}
+	for {
+		page, response, err := c.git.AwardEmoji.ListMergeRequestAwardEmoji(pid, mid, opts)
+		if err != nil {
+			return nil, err
+		}
+		awards = append(awards, page...)
+
+		if response.NextPage == 0 {
+			return awards, nil
+		}
+		opts.Page = response.NextPage
+	}
+}
+
+func (c *gitlabClient) CreateAward(pid int, mid int, name string) error {
+	opts := &gitlab.CreateAwardEmojiOptions{Name: name}
+	_, _, err := c.git.AwardEmoji.CreateMergeRequestAwardEmoji(pid, mid, opts)
+	return err
+}
+
+func (c *gitlabClient) DeleteAward(pid int, mid int, aid int) error {
+	_, err := c.git.AwardEmoji.DeleteMergeRequestAwardEmoji(pid, mid, aid)
+	return err
+}
+
+func (c *gitlabClient) ListBranches(pid int, opts *gitlab.ListBranchesOptions) ([]*gitlab.Branch, int, error) {
+	branches, response, err := c.git.Branches.ListBranches(pid, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return branches, response.NextPage, nil
+}
+
+func (c *gitlabClient) ListProtectedBranches(pid int) ([]string, error) {
+	var protected_branches []string
+
+	pbs_opts := &gitlab.ListProtectedBranchesOptions{}
+	pbs, _, err := c.git.ProtectedBranches.ListProtectedBranches(pid, pbs_opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, pb := range pbs {
+		protected_branches = append(protected_branches, pb.Name)
+	}
+
+	return protected_branches, nil
+}
+
+func (c *gitlabClient) GetProject(pid int) (*gitlab.Project, error) {
+	prj, _, err := c.git.Projects.GetProject(pid, &gitlab.GetProjectOptions{})
+	return prj, err
+}
+
+func (c *gitlabClient) CreateNote(pid int, mid int, body string) error {
+	noteOpts := gitlab.CreateMergeRequestNoteOptions{
+		Body: &body,
+	}
+	_, _, err := c.git.Notes.CreateMergeRequestNote(pid, mid, &noteOpts)
+	return err
+}
+
+func (c *gitlabClient) DeleteBranch(pid int, branch string) error {
+	_, err := c.git.Branches.DeleteBranch(pid, branch)
+	return err
+}
+
+func (c *gitlabClient) CreateTag(pid int, tag string, ref string) error {
+	opts := &gitlab.CreateTagOptions{
+		TagName: gitlab.String(tag),
+		Ref:     gitlab.String(ref),
+	}
+	_, _, err := c.git.Tags.CreateTag(pid, opts)
+	return err
+}
+
+func (c *gitlabClient) ListNotes(pid int, mid int) ([]*gitlab.Note, error) {
+	var notes []*gitlab.Note
+
+	opts := &gitlab.ListMergeRequestNotesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1},
+	}
+	for {
+		page, response, err := c.git.Notes.ListMergeRequestNotes(pid, mid, opts)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, page...)
+
+		if response.NextPage == 0 {
+			return notes, nil
+		}
+		opts.Page = response.NextPage
+	}
+}
+
+func (c *gitlabClient) ListChanges(pid int, mid int) ([]string, error) {
+	var files []string
+
+	mr, _, err := c.git.MergeRequests.GetMergeRequestChanges(pid, mid)
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range mr.Changes {
+		files = append(files, change.NewPath)
+		if change.OldPath != change.NewPath {
+			files = append(files, change.OldPath)
+		}
+	}
+
+	return files, nil
+}
+
+func (c *gitlabClient) GetRawFile(pid int, file string, ref string) ([]byte, error) {
+	opts := &gitlab.GetRawFileOptions{Ref: gitlab.String(ref)}
+	data, _, err := c.git.RepositoryFiles.GetRawFile(pid, file, opts)
+	return data, err
+}
+
+func (c *gitlabClient) LatestVersion(pid int, mid int) (*gitlab.MergeRequestDiffVersion, error) {
+	opts := &gitlab.GetMergeRequestDiffVersionsOptions{PerPage: 1, Page: 1}
+	versions, _, err := c.git.MergeRequests.GetMergeRequestDiffVersions(pid, mid, opts)
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return versions[0], nil
+}
+
+func (c *gitlabClient) ListApprovers(pid int, mid int) ([]string, error) {
+	var approvers []string
+
+	approvals, _, err := c.git.MergeRequestApprovals.GetConfiguration(pid, mid)
+	if err != nil {
+		return nil, err
+	}
+	for _, approver := range approvals.ApprovedBy {
+		if approver.User != nil {
+			approvers = append(approvers, approver.User.Username)
+		}
+	}
+
+	return approvers, nil
+}
+
+func (c *gitlabClient) LatestPipeline(pid int, mid int) (*gitlab.PipelineInfo, error) {
+	pipelines, _, err := c.git.MergeRequests.ListMergeRequestPipelines(pid, mid)
+	if err != nil || len(pipelines) == 0 {
+		return nil, err
+	}
+	return pipelines[0], nil
+}
+
+func (c *gitlabClient) UnresolvedDiscussions(pid int, mid int) (int, error) {
+	var unresolved int
+
+	opts := &gitlab.ListMergeRequestDiscussionsOptions{PerPage: 100, Page: 1}
+	for {
+		discussions, response, err := c.git.Discussions.ListMergeRequestDiscussions(pid, mid, opts)
+		if err != nil {
+			return 0, err
+		}
+		for _, discussion := range discussions {
+			for _, note := range discussion.Notes {
+				if note.Resolvable && !note.Resolved {
+					unresolved++
+					break
+				}
+			}
+		}
+
+		if response.NextPage == 0 {
+			return unresolved, nil
+		}
+		opts.Page = response.NextPage
+	}
+}
+
+func (c *gitlabClient) UpdateNote(pid int, mid int, nid int, body string) error {
+	opts := &gitlab.UpdateMergeRequestNoteOptions{Body: gitlab.String(body)}
+	_, _, err := c.git.Notes.UpdateMergeRequestNote(pid, mid, nid, opts)
+	return err
+}
+
+func (c *gitlabClient) ListGroupMembers(group string) ([]*gitlab.GroupMember, error) {
+	var members []*gitlab.GroupMember
+
+	opts := &gitlab.ListGroupMembersOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1},
+	}
+	for {
+		page, response, err := c.git.Groups.ListAllGroupMembers(group, opts)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, page...)
+
+		if response.NextPage == 0 {
+			return members, nil
+		}
+		opts.Page = response.NextPage
+	}
+}
+
+func (c *gitlabClient) UserExists(username string) (bool, error) {
+	opts := &gitlab.ListUsersOptions{Username: gitlab.String(username)}
+	users, _, err := c.git.Users.ListUsers(opts)
+	if err != nil {
+		return false, err
+	}
+	return len(users) > 0, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewGitClientUsername(t *testing.T) {
+	calls := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v4/user" {
+			http.NotFound(w, r)
+			return
+		}
+		token := r.Header.Get("Private-Token")
+		calls[token]++
+		fmt.Fprintf(w, `{"id": 1, "username": "Ward-%v"}`, token)
+	}))
+	defer server.Close()
+
+	cfg := testConfig()
+	cfg.Endpoints.GitLab = server.URL
+
+	for _, token := range []string{"first", "first", "second", "first"} {
+		cfg.GitLab.Token = token
+		git, err := newGitClient(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "ward-" + token; git.Username() != want {
+			t.Errorf("username = %v, want %v", git.Username(), want)
+		}
+	}
+
+	if calls["first"] != 1 || calls["second"] != 1 {
+		t.Errorf("user requests = %v, want one per token", calls)
+	}
+}
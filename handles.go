@@ -1,10 +1,15 @@
 package main
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 )
 
 func handler(w http.ResponseWriter, r *http.Request) {
@@ -12,10 +17,17 @@ func handler(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleMR(w http.ResponseWriter, r *http.Request) {
-	var cfg config
-	cfg.getConfig()
+	cfg := currentConfig()
 
-	mrs, err := checkPrjRequests(cfg, cfg.Projects, "any")
+	git, err := newGitClient(cfg)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	cfg = cfg.resolveTeams(git)
+
+	mrs, err := checkPrjRequests(cfg, git, cfg.Projects, "any", nil)
 	if err != nil {
 		log.Println(err)
 	}
@@ -28,10 +40,17 @@ func handleMR(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleMROpened(w http.ResponseWriter, r *http.Request) {
-	var cfg config
-	cfg.getConfig()
+	cfg := currentConfig()
+
+	git, err := newGitClient(cfg)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	cfg = cfg.resolveTeams(git)
 
-	mrs, err := checkPrjRequests(cfg, cfg.Projects, "opened")
+	mrs, err := checkPrjRequests(cfg, git, cfg.Projects, "opened", nil)
 	if err != nil {
 		log.Println(err)
 	}
@@ -42,10 +61,17 @@ func handleMROpened(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleMRMerged(w http.ResponseWriter, r *http.Request) {
-	var cfg config
-	cfg.getConfig()
+	cfg := currentConfig()
+
+	git, err := newGitClient(cfg)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	cfg = cfg.resolveTeams(git)
 
-	mrs, err := checkPrjRequests(cfg, cfg.Projects, "merged")
+	mrs, err := checkPrjRequests(cfg, git, cfg.Projects, "merged", nil)
 	if err != nil {
 		log.Println(err)
 	}
@@ -60,8 +86,7 @@ func handleMRMerged(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleMRApply(w http.ResponseWriter, r *http.Request) {
-	var cfg config
-	cfg.getConfig()
+	cfg := currentConfig()
 
 	data := detectMR(cfg)
 
@@ -73,10 +98,17 @@ func handleMRApply(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleDead(w http.ResponseWriter, r *http.Request) {
-	var cfg config
-	cfg.getConfig()
+	cfg := currentConfig()
+
+	git, err := newGitClient(cfg)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	cfg = cfg.resolveTeams(git)
 
-	data := detectDead(cfg)
+	data := detectDead(cfg, git)
 	undead, _ := json.Marshal(data)
 
 	output := fmt.Sprintf("%v", string(undead))
@@ -86,12 +118,20 @@ func handleDead(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleDeadLetter(w http.ResponseWriter, r *http.Request) {
-	var cfg config
-	cfg.getConfig()
+	cfg := currentConfig()
+
+	git, err := newGitClient(cfg)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	cfg = cfg.resolveTeams(git)
 
-	undead := detectDead(cfg)
+	undead := detectDead(cfg, git)
 	for _, v := range undead.Authors {
 		v.Projects = undead.Projects
+		v.KeepLabel = cfg.keepLabel()
 		template, err := deadAuthorTemplate(v)
 		if err != nil {
 			fmt.Fprint(w, err)
@@ -101,3 +141,152 @@ func handleDeadLetter(w http.ResponseWriter, r *http.Request) {
 		break
 	}
 }
+
+// gitlabHook holds the fields ward needs from MR, Note, Emoji and Pipeline
+// events
+type gitlabHook struct {
+	ObjectKind string `json:"object_kind"`
+	Project    struct {
+		ID int `json:"id"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		IID           int    `json:"iid"`
+		NoteableType  string `json:"noteable_type"`
+		AwardableType string `json:"awardable_type"`
+	} `json:"object_attributes"`
+	MergeRequest struct {
+		IID int `json:"iid"`
+	} `json:"merge_request"`
+}
+
+// mergeRequest returns IID of the MR affected by the event or 0
+func (h gitlabHook) mergeRequest() int {
+	switch h.ObjectKind {
+	case "merge_request":
+		return h.ObjectAttributes.IID
+	case "note":
+		if h.ObjectAttributes.NoteableType == "MergeRequest" {
+			return h.MergeRequest.IID
+		}
+	case "emoji":
+		if h.ObjectAttributes.AwardableType == "MergeRequest" {
+			return h.MergeRequest.IID
+		}
+	case "pipeline":
+		// Set for merge request pipelines only
+		return h.MergeRequest.IID
+	}
+	return 0
+}
+
+// detectHook evaluates MR affected by webhook event, replaced in tests
+var detectHook = detectHookMR
+
+func handleGitlabHook(w http.ResponseWriter, r *http.Request) {
+	var hook gitlabHook
+	cfg := currentConfig()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.Header.Get("X-Gitlab-Token")
+	if cfg.Webhook.Token == "" ||
+		subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Webhook.Token)) != 1 {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if err := json.Unmarshal(body, &hook); err != nil {
+		http.Error(w, "failed to parse body", http.StatusBadRequest)
+		return
+	}
+
+	mid := hook.mergeRequest()
+	if _, found := cfg.Projects[hook.Project.ID]; !found || mid == 0 {
+		fmt.Fprint(w, "ignored")
+		return
+	}
+
+	// GitLab expects a quick answer so evaluation goes on in background
+	go detectHook(cfg, hook.Project.ID, mid)
+
+	fmt.Fprint(w, "ok")
+}
+
+// queryID parses optional numeric parameter, zero if it is not set
+func queryID(query url.Values, name string) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("%v must be a number", name)
+	}
+	return id, nil
+}
+
+func handleMRHistory(w http.ResponseWriter, r *http.Request) {
+	pid, err := queryID(r.URL.Query(), "project")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mid, err := queryID(r.URL.Query(), "mr")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, err := history.listActions(pid, mid)
+	if err != nil {
+		log.Println(err)
+	}
+
+	out, _ := json.Marshal(records)
+	output := fmt.Sprintf("%v", string(out))
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, output)
+}
+
+func handleAuditNonCompliant(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	var err error
+
+	query := r.URL.Query()
+
+	pid, err := queryID(query, "project")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if value := query.Get("since"); value != "" {
+		if since, err = time.Parse(time.RFC3339, value); err != nil {
+			if since, err = time.Parse("2006-01-02", value); err != nil {
+				http.Error(w, "since must be RFC3339 or YYYY-MM-DD", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	merges, err := history.nonCompliant(pid, since, query.Get("user"))
+	if err != nil {
+		log.Println(err)
+	}
+
+	out, _ := json.Marshal(merges)
+	output := fmt.Sprintf("%v", string(out))
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, output)
+}
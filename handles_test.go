@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleMRHistory(t *testing.T) {
+	s := useStore(t)
+	for _, action := range []mrAction{
+		{Pid: 1, Mid: 1, Award: "ready", State: true},
+		{Pid: 1, Mid: 2, Award: "ready", State: true},
+	} {
+		if err := s.addAction(action); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query    string
+		wantCode int
+		want     int
+	}{
+		{"", http.StatusOK, 2},
+		{"?project=1&mr=2", http.StatusOK, 1},
+		{"?project=2", http.StatusOK, 0},
+		{"?project=abc", http.StatusBadRequest, 0},
+		{"?project=1&mr=-1", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handleMRHistory(w, httptest.NewRequest(http.MethodGet, "/mr/history"+tt.query, nil))
+
+		if w.Code != tt.wantCode {
+			t.Errorf("%q: code = %v, want %v", tt.query, w.Code, tt.wantCode)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+
+		var records []mrRecord
+		if err := json.Unmarshal(w.Body.Bytes(), &records); err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		if len(records) != tt.want {
+			t.Errorf("%q: %v records, want %v", tt.query, len(records), tt.want)
+		}
+	}
+}
+
+func TestHandleAuditNonCompliant(t *testing.T) {
+	s := useStore(t)
+	for _, action := range []mrAction{
+		{Pid: 1, Mid: 1, Award: "nc", State: true, MergedBy: "alice"},
+		{Pid: 2, Mid: 1, Award: "nc", State: true, MergedBy: "bob"},
+	} {
+		if err := s.addAction(action); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query    string
+		wantCode int
+		want     int
+	}{
+		{"", http.StatusOK, 2},
+		{"?project=2", http.StatusOK, 1},
+		{"?user=alice", http.StatusOK, 1},
+		{"?since=2000-01-01", http.StatusOK, 2},
+		{"?since=2999-01-01T00:00:00Z", http.StatusOK, 0},
+		{"?since=yesterday", http.StatusBadRequest, 0},
+		{"?project=one", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handleAuditNonCompliant(w, httptest.NewRequest(http.MethodGet, "/audit/noncompliant"+tt.query, nil))
+
+		if w.Code != tt.wantCode {
+			t.Errorf("%q: code = %v, want %v", tt.query, w.Code, tt.wantCode)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+
+		var merges []ncMerge
+		if err := json.Unmarshal(w.Body.Bytes(), &merges); err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		if len(merges) != tt.want {
+			t.Errorf("%q: %v merges, want %v", tt.query, len(merges), tt.want)
+		}
+	}
+}
+
+func TestHandleGitlabHook(t *testing.T) {
+	saved, savedDetect := currentConfig(), detectHook
+	defer func() {
+		storeConfig(saved)
+		detectHook = savedDetect
+	}()
+
+	detected := make(chan [2]int, 1)
+	detectHook = func(cfg config, pid int, mid int) []mrAction {
+		detected <- [2]int{pid, mid}
+		return nil
+	}
+
+	cfg := testConfig()
+	cfg.Webhook.Token = "secret"
+	cfg.Projects = map[int]*Project{1: {Teams: map[string][]string{"Backend": {"alice"}}}}
+
+	tests := []struct {
+		name     string
+		method   string
+		token    string
+		body     string
+		wantCode int
+		wantBody string
+		want     [2]int
+	}{
+		{"wrong method", http.MethodGet, "secret", "", http.StatusMethodNotAllowed, "", [2]int{}},
+		{"missing token", http.MethodPost, "", `{"object_kind":"note"}`, http.StatusUnauthorized, "", [2]int{}},
+		{"wrong token", http.MethodPost, "secreT", `{"object_kind":"note"}`, http.StatusUnauthorized, "", [2]int{}},
+		{"broken payload", http.MethodPost, "secret", `{"object_kind":`, http.StatusBadRequest, "", [2]int{}},
+		{"unknown event", http.MethodPost, "secret",
+			`{"object_kind":"push","project":{"id":1}}`, http.StatusOK, "ignored", [2]int{}},
+		{"issue note", http.MethodPost, "secret",
+			`{"object_kind":"note","project":{"id":1},"object_attributes":{"noteable_type":"Issue"}}`,
+			http.StatusOK, "ignored", [2]int{}},
+		{"other project", http.MethodPost, "secret",
+			`{"object_kind":"merge_request","project":{"id":2},"object_attributes":{"iid":3}}`,
+			http.StatusOK, "ignored", [2]int{}},
+		{"merge request", http.MethodPost, "secret",
+			`{"object_kind":"merge_request","project":{"id":1},"object_attributes":{"iid":3}}`,
+			http.StatusOK, "ok", [2]int{1, 3}},
+		{"note", http.MethodPost, "secret",
+			`{"object_kind":"note","project":{"id":1},"object_attributes":{"noteable_type":"MergeRequest"},"merge_request":{"iid":4}}`,
+			http.StatusOK, "ok", [2]int{1, 4}},
+		{"emoji", http.MethodPost, "secret",
+			`{"object_kind":"emoji","project":{"id":1},"object_attributes":{"awardable_type":"MergeRequest"},"merge_request":{"iid":5}}`,
+			http.StatusOK, "ok", [2]int{1, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storeConfig(cfg)
+
+			r := httptest.NewRequest(tt.method, "/hooks/gitlab", strings.NewReader(tt.body))
+			if tt.token != "" {
+				r.Header.Set("X-Gitlab-Token", tt.token)
+			}
+			w := httptest.NewRecorder()
+			handleGitlabHook(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("code = %v, want %v", w.Code, tt.wantCode)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+
+			if tt.want == [2]int{} {
+				select {
+				case got := <-detected:
+					t.Errorf("MR %v was evaluated", got)
+				default:
+				}
+				return
+			}
+			select {
+			case got := <-detected:
+				if got != tt.want {
+					t.Errorf("evaluated %v, want %v", got, tt.want)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("MR was not evaluated")
+			}
+		})
+	}
+
+	// Webhooks are disabled without a token
+	cfg.Webhook.Token = ""
+	storeConfig(cfg)
+	w := httptest.NewRecorder()
+	handleGitlabHook(w, httptest.NewRequest(http.MethodPost, "/hooks/gitlab", strings.NewReader(`{}`)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("code = %v without configured token, want %v", w.Code, http.StatusUnauthorized)
+	}
+}
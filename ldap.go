@@ -6,6 +6,9 @@ import (
 	"github.com/go-ldap/ldap/v3"
 )
 
+// ldapUsers matches enabled user accounts, %v is the rest of the filter
+const ldapUsers = "(&(objectClass=user)(objectCategory=person)(!(userAccountControl:1.2.840.113556.1.4.803:=2))%v)"
+
 func ldapCheck(cfg config, email string) bool {
 	filter := fmt.Sprintf("(mail=%v)", email)
 
@@ -42,9 +45,9 @@ func ldapRequest(cfg config, filter string) []string {
 
 	defer conn.Close()
 
-	filter = fmt.Sprintf("(&(objectClass=user)(objectCategory=person)(!(userAccountControl:1.2.840.113556.1.4.803:=2))(|%v))", filter)
+	filter = fmt.Sprintf(ldapUsers, fmt.Sprintf("(|%v)", filter))
 
-	if mail, err = ldapList(conn, cfg.Endpoints.DC.Base, filter); err != nil {
+	if mail, err = ldapList(conn, cfg.Endpoints.DC.Base, filter, "mail"); err != nil {
 		fmt.Printf("%v", err)
 		return nil
 	}
@@ -52,6 +55,22 @@ func ldapRequest(cfg config, filter string) []string {
 	return mail
 }
 
+// ldapGroup returns account names of enabled users in the group including
+// members of nested groups
+func ldapGroup(cfg config, dn string) ([]string, error) {
+	conn, err := ldapConnect(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	filter := fmt.Sprintf(ldapUsers,
+		fmt.Sprintf("(memberOf:1.2.840.113556.1.4.1941:=%v)", ldap.EscapeFilter(dn)))
+
+	return ldapList(conn, cfg.Endpoints.DC.Base, filter, "sAMAccountName")
+}
+
 func ldapConnect(cfg config) (*ldap.Conn, error) {
 	addr := fmt.Sprintf("%v:%v", cfg.Endpoints.DC.Host, cfg.Endpoints.DC.Port)
 	conn, err := ldap.Dial("tcp", addr)
@@ -69,8 +88,8 @@ func ldapConnect(cfg config) (*ldap.Conn, error) {
 	return conn, nil
 }
 
-func ldapList(conn *ldap.Conn, base string, filter string) ([]string, error) {
-	var mail []string
+func ldapList(conn *ldap.Conn, base string, filter string, attribute string) ([]string, error) {
+	var values []string
 
 	result, err := conn.Search(ldap.NewSearchRequest(
 		base,
@@ -80,17 +99,17 @@ func ldapList(conn *ldap.Conn, base string, filter string) ([]string, error) {
 		0,
 		false,
 		filter,
-		[]string{"mail"},
+		[]string{attribute},
 		nil,
 	))
 
 	if err != nil {
-		return mail, fmt.Errorf("Failed to search users. %s", err)
+		return values, fmt.Errorf("Failed to search users. %s", err)
 	}
 
 	for _, entry := range result.Entries {
-		mail = append(mail, entry.GetAttributeValue("mail"))
+		values = append(values, entry.GetAttributeValue(attribute))
 	}
 
-	return mail, nil
+	return values, nil
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -12,25 +13,60 @@ import (
 )
 
 func main() {
-	var cfg config
-	cfg.getConfig()
+	flag.StringVar(&configFile, "config", configFile, "path to config file")
+	flag.Parse()
+
+	if flag.Arg(0) == "check-config" {
+		os.Exit(checkConfig(configFile, os.Stdout))
+	}
 
 	log.SetOutput(os.Stdout)
 
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	storeConfig(cfg)
+	go watchConfig(configFile)
+
+	storePath := cfg.Store
+	if storePath == "" {
+		storePath = "ward.db"
+	}
+	history, err = openStore(storePath)
+	if err != nil {
+		log.Printf("History is disabled: %v", err)
+	}
+	if mergedWatermark, err = history.watermarks(); err != nil {
+		log.Printf("Failed to load watermarks: %v", err)
+	}
+
 	s, err := scheduler.NewScheduler(1000)
 	if err != nil {
 		panic(err)
 	}
-	s.Every().Second(15).Do(detectMR, cfg)
-	s.Every().Second(0).Minute(0).Hour(2).Weekday(1).Do(detectDeadBrunches, cfg)
+	// Jobs take the config current at the time they run, while schedule
+	// changes require restart
+	applyMR := func() { detectMR(currentConfig()) }
+	if cfg.Webhook.Token != "" {
+		// Webhooks do the job, polling is only a reconciliation fallback
+		s.Every().Second(15).Minute(0).Do(applyMR)
+	} else {
+		s.Every().Second(15).Do(applyMR)
+	}
+	s.Every().Second(0).Minute(0).Hour(2).Weekday(1).Do(func() { detectDeadBrunches(currentConfig()) })
+	s.Every().Second(30).Minute(5).Do(func() { detectStalledMR(currentConfig()) })
 
 	http.HandleFunc("/", handler)
 	http.HandleFunc("/mr", handleMR)
 	http.HandleFunc("/mr/opened", handleMROpened)
 	http.HandleFunc("/mr/merged", handleMRMerged)
 	http.HandleFunc("/mr/apply", handleMRApply)
+	http.HandleFunc("/mr/history", handleMRHistory)
 	http.HandleFunc("/dead", handleDead)
 	http.HandleFunc("/dead/letter", handleDeadLetter)
+	http.HandleFunc("/hooks/gitlab", handleGitlabHook)
+	http.HandleFunc("/audit/noncompliant", handleAuditNonCompliant)
 
 	server := &http.Server{
 		Addr:         ":8081",
@@ -51,4 +87,5 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	_ = server.Shutdown(ctx)
+	_ = history.Close()
 }
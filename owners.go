@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// codeOwnersFiles are locations of CODEOWNERS in the order GitLab checks them
+var codeOwnersFiles = []string{"CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"}
+
+// requiredTeams returns teams which have to vote for the MR. Owners of
+// changed files are found among all teams of the project and narrowed to
+// teams of the branch policy. Policy teams owning no paths are always
+// required, all policy teams are required if nothing else is left.
+func requiredTeams(git gitClient, project *Project, pid int, mr *gitlab.MergeRequest) map[string][]string {
+	scoped := project.forBranch(mr.TargetBranch)
+	policy := project.policyFor(mr.TargetBranch)
+	owners, pathTeams := ownerTeams(git, project, pid, mr)
+
+	required := make(map[string][]string)
+	for team, members := range scoped.Teams {
+		_, owner := owners[team]
+		if owner || policy != nil && len(policy.Teams) > 0 && !pathTeams[team] {
+			required[team] = members
+		}
+	}
+	if len(required) == 0 {
+		return scoped.Teams
+	}
+
+	return required
+}
+
+// ownerTeams returns teams owning changed files and teams named by path
+// rules. With path rules only teams owning changed files are required, the
+// last matching rule wins like in CODEOWNERS. All teams are required if any
+// file has no owner, its owners are in no team or rules could not be
+// evaluated.
+func ownerTeams(git gitClient, project *Project, pid int, mr *gitlab.MergeRequest) (map[string][]string, map[string]bool) {
+	var err error
+
+	if len(project.Paths) == 0 && !project.CodeOwners {
+		return project.Teams, nil
+	}
+
+	rules := project.Paths
+	if project.CodeOwners {
+		rules, err = loadCodeOwners(git, pid, mr.TargetBranch, project.Teams)
+		if err != nil {
+			log.Printf("Failed to load CODEOWNERS of %v: %v", pid, err)
+			return project.Teams, nil
+		}
+	}
+
+	files, err := git.ListChanges(pid, mr.IID)
+	if err != nil {
+		log.Printf("Failed to list changes of %v/%v: %v", pid, mr.IID, err)
+		return project.Teams, nil
+	}
+	if len(files) == 0 {
+		return project.Teams, nil
+	}
+
+	pathTeams := make(map[string]bool)
+	for _, rule := range rules {
+		for _, team := range rule.Teams {
+			pathTeams[team] = true
+		}
+	}
+
+	required := make(map[string][]string)
+	for _, file := range files {
+		var owners []string
+		matched := false
+
+		for _, rule := range rules {
+			if matchPath(rule.Pattern, file) {
+				owners = rule.Teams
+				matched = true
+			}
+		}
+		if !matched {
+			return project.Teams, nil
+		}
+
+		owned := false
+		for _, team := range owners {
+			if members, found := project.Teams[team]; found {
+				required[team] = members
+				owned = true
+			}
+		}
+		if !owned {
+			return project.Teams, nil
+		}
+	}
+
+	return required, pathTeams
+}
+
+// loadCodeOwners reads CODEOWNERS from the branch and turns it into rules
+func loadCodeOwners(git gitClient, pid int, ref string, teams map[string][]string) ([]pathRule, error) {
+	for _, file := range codeOwnersFiles {
+		data, err := git.GetRawFile(pid, file, ref)
+		if err == nil {
+			return parseCodeOwners(data, teams), nil
+		}
+	}
+
+	return nil, fmt.Errorf("no CODEOWNERS found in %v", ref)
+}
+
+// parseCodeOwners maps owners of each CODEOWNERS entry to teams. Owner is
+// a team if its name or the last part of group path matches the team name,
+// a username belongs to every team it is a member of.
+func parseCodeOwners(data []byte, teams map[string][]string) []pathRule {
+	var rules []pathRule
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") ||
+			strings.HasPrefix(line, "[") || strings.HasPrefix(line, "^[") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		rule := pathRule{Pattern: fields[0]}
+
+		for _, owner := range fields[1:] {
+			owner = strings.ToLower(strings.TrimPrefix(owner, "@"))
+			group := owner[strings.LastIndex(owner, "/")+1:]
+
+			for team, members := range teams {
+				if (strings.ToLower(team) == group || contains(members, owner)) &&
+					!contains(rule.Teams, team) {
+					rule.Teams = append(rule.Teams, team)
+				}
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// matchPath matches file against CODEOWNERS pattern. Pattern without slashes
+// matches a file or directory at any depth, otherwise it is relative to the
+// repository root. Matching a directory matches everything inside it.
+func matchPath(pattern string, file string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	expr := globRegexp(pattern)
+	if !anchored {
+		expr = "(.*/)?" + expr
+	}
+
+	re, err := regexp.Compile("^" + expr + "(/.*)?$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(strings.TrimPrefix(file, "/"))
+}
+
+// globRegexp converts glob with ** support to regular expression
+func globRegexp(glob string) string {
+	var expr strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			expr.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			expr.WriteString("[^/]*")
+		case glob[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(glob[i])))
+		}
+	}
+
+	return expr.String()
+}
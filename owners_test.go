@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/ward/main.go", true},
+		{"*.go", "main.js", false},
+		{"docs", "docs/README.md", true},
+		{"docs/", "guide/docs/README.md", true},
+		{"/docs/", "guide/docs/README.md", false},
+		{"/web/", "web/app/index.js", true},
+		{"web/*.js", "web/index.js", true},
+		{"web/*.js", "web/app/index.js", false},
+		{"web/**/*.js", "web/app/index.js", true},
+		{"web/**/*.js", "web/index.js", true},
+		{"/README.md", "README.md", true},
+		{"/README.md", "docs/README.md", false},
+		{"file?.txt", "file1.txt", true},
+	}
+
+	for _, tt := range tests {
+		if got := matchPath(tt.pattern, tt.file); got != tt.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tt.pattern, tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestParseCodeOwners(t *testing.T) {
+	teams := map[string][]string{"Backend": {"alice"}, "Frontend": {"bob"}}
+	data := `
+# comment
+[Section]
+*.go @alice
+/web/ @my-org/frontend @carol
+*.md @someone
+`
+	want := []pathRule{
+		{Pattern: "*.go", Teams: []string{"Backend"}},
+		{Pattern: "/web/", Teams: []string{"Frontend"}},
+		{Pattern: "*.md"},
+	}
+
+	if got := parseCodeOwners([]byte(data), teams); !reflect.DeepEqual(got, want) {
+		t.Errorf("rules = %+v, want %+v", got, want)
+	}
+}
+
+func TestRequiredTeams(t *testing.T) {
+	teams := map[string][]string{"Backend": {"alice"}, "Frontend": {"bob"}, "Docs": {"carol"}}
+	rules := []pathRule{
+		{Pattern: "*", Teams: []string{"Backend"}},
+		{Pattern: "/web/", Teams: []string{"Frontend"}},
+		{Pattern: "*.md", Teams: []string{"Docs"}},
+	}
+	policy := []branchPolicy{{Branch: "master", Teams: []string{"Backend"}}}
+	withSecurity := map[string][]string{"Backend": {"alice"}, "Frontend": {"bob"}, "Docs": {"carol"}, "Security": {"dave"}}
+	securityPolicy := []branchPolicy{{Branch: "master", Teams: []string{"Frontend", "Security"}}}
+
+	tests := []struct {
+		name       string
+		project    Project
+		files      []string
+		codeOwners string
+		want       []string
+	}{
+		{"no rules", Project{Teams: teams}, []string{"main.go"}, "", []string{"Backend", "Docs", "Frontend"}},
+		{"backend only", Project{Teams: teams, Paths: rules}, []string{"main.go"}, "", []string{"Backend"}},
+		{"last rule wins", Project{Teams: teams, Paths: rules}, []string{"web/app.js", "web/README.md"}, "", []string{"Docs", "Frontend"}},
+		{"unowned file", Project{Teams: teams, Paths: rules[1:]}, []string{"main.go", "web/app.js"}, "", []string{"Backend", "Docs", "Frontend"}},
+		{"codeowners", Project{Teams: teams, CodeOwners: true}, []string{"web/app.js"}, "/web/ @bob", []string{"Frontend"}},
+		{"codeowners unknown owner", Project{Teams: teams, CodeOwners: true}, []string{"README.md"}, "*.md @someone", []string{"Backend", "Docs", "Frontend"}},
+		{"rule without team", Project{Teams: teams, Paths: []pathRule{{Pattern: "*.md", Teams: []string{"QA"}}}}, []string{"README.md"}, "", []string{"Backend", "Docs", "Frontend"}},
+		{"policy narrows owners", Project{Teams: teams, Paths: rules, Policies: policy}, []string{"web/app.js", "main.go"}, "", []string{"Backend"}},
+		{"policy without owners", Project{Teams: teams, Paths: rules, Policies: policy}, []string{"web/app.js"}, "", []string{"Backend"}},
+		{"policy without rules", Project{Teams: teams, Policies: policy}, []string{"web/app.js"}, "", []string{"Backend"}},
+		{"policy team without paths", Project{Teams: withSecurity, Paths: rules, Policies: securityPolicy}, []string{"web/app.js"}, "", []string{"Frontend", "Security"}},
+		{"policy team without paths only", Project{Teams: withSecurity, Paths: rules, Policies: securityPolicy}, []string{"main.go"}, "", []string{"Security"}},
+		{"team without paths and policy", Project{Teams: withSecurity, Paths: rules}, []string{"web/app.js"}, "", []string{"Frontend"}},
+		{"codeowners missing", Project{Teams: teams, CodeOwners: true}, []string{"web/app.js"}, "", []string{"Backend", "Docs", "Frontend"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			git := newFakeGit()
+			mr := testMR(1, "opened", "master")
+			git.changes[1] = map[int][]string{1: tt.files}
+			if tt.codeOwners != "" {
+				git.files[1] = map[string]string{"master:.gitlab/CODEOWNERS": tt.codeOwners}
+			}
+
+			var got []string
+			for team := range requiredTeams(git, &tt.project, 1, mr) {
+				got = append(got, team)
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("teams = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
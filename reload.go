@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// sharedConfig holds config used by the scheduler and handlers, it is
+// swapped as a whole on reload
+var sharedConfig atomic.Value
+
+func currentConfig() config {
+	cfg, _ := sharedConfig.Load().(config)
+	return cfg
+}
+
+func storeConfig(cfg config) {
+	sharedConfig.Store(cfg)
+}
+
+// reloadConfig swaps config if the new one is valid, the current config is
+// kept otherwise
+func reloadConfig(path string) {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		log.Printf("Failed to reload config, keeping the current one: %v", err)
+		return
+	}
+
+	for _, change := range configDiff(currentConfig(), cfg) {
+		log.Printf("Config: %v", change)
+	}
+	storeConfig(cfg)
+	log.Printf("Config reloaded from %v", path)
+}
+
+// configDiff lists projects, teams and members added or removed
+func configDiff(old config, cfg config) []string {
+	var changes []string
+
+	for pid, project := range cfg.Projects {
+		prev, found := old.Projects[pid]
+		if !found {
+			changes = append(changes, fmt.Sprintf("project %v added", pid))
+			continue
+		}
+
+		for team, members := range project.Teams {
+			prevMembers, found := prev.Teams[team]
+			if !found {
+				changes = append(changes, fmt.Sprintf("team %v added to project %v", team, pid))
+				continue
+			}
+			for _, user := range members {
+				if !contains(prevMembers, user) {
+					changes = append(changes, fmt.Sprintf("%v added to team %v of project %v", user, team, pid))
+				}
+			}
+			for _, user := range prevMembers {
+				if !contains(members, user) {
+					changes = append(changes, fmt.Sprintf("%v removed from team %v of project %v", user, team, pid))
+				}
+			}
+		}
+		for team := range prev.Teams {
+			if _, found := project.Teams[team]; !found {
+				changes = append(changes, fmt.Sprintf("team %v removed from project %v", team, pid))
+			}
+		}
+	}
+	for pid := range old.Projects {
+		if _, found := cfg.Projects[pid]; !found {
+			changes = append(changes, fmt.Sprintf("project %v removed", pid))
+		}
+	}
+
+	sort.Strings(changes)
+	return changes
+}
+
+// watchConfig reloads config on SIGHUP and when the file changes. The
+// directory is watched as editors often replace files instead of writing.
+func watchConfig(path string) {
+	var changed <-chan fsnotify.Event
+	var failed <-chan error
+	var reload <-chan time.Time
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("Failed to watch config, reload it with SIGHUP: %v", err)
+	} else if err := watcher.Add(filepath.Dir(path)); err != nil {
+		log.Printf("Failed to watch config, reload it with SIGHUP: %v", err)
+		watcher.Close()
+	} else {
+		defer watcher.Close()
+		changed = watcher.Events
+		failed = watcher.Errors
+	}
+
+	for {
+		select {
+		case <-hup:
+			reloadConfig(path)
+		case event := <-changed:
+			if filepath.Clean(event.Name) != filepath.Clean(path) ||
+				event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			// Wait for the file to be written completely
+			reload = time.After(time.Second)
+		case <-reload:
+			reloadConfig(path)
+		case err := <-failed:
+			log.Printf("Failed to watch config: %v", err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testConfigYAML = `
+Endpoints:
+  DC:
+    Host: ad.example.com
+    Port: 389
+  SMTP:
+    Host: smtp.example.com
+    Port: 587
+  GitLab: https://git.example.com
+Awards:
+  Like: thumbsup
+  Dislike: thumbsdown
+  Ready: heavy_check_mark
+  NotReady: x
+  NonCompliant: poop
+Projects:
+  1:
+    Teams:
+      Backend: [alice, bob]
+      Frontend: [carol]
+`
+
+func writeConfig(t *testing.T, data string) string {
+	dir, err := ioutil.TempDir("", "ward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"valid", testConfigYAML, false},
+		{"broken yaml", "Projects: [", true},
+		{"no endpoint", "Projects: {}", true},
+		{"no teams", testConfigYAML + "  2:\n    Votes: 1\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(writeConfig(t, tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := loadConfig(filepath.Join(os.TempDir(), "missing-ward.yaml")); err == nil {
+		t.Error("missing config was loaded")
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	defer storeConfig(config{})
+
+	path := writeConfig(t, testConfigYAML)
+	reloadConfig(path)
+	if _, found := currentConfig().Projects[1]; !found {
+		t.Fatal("config was not loaded")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("Projects: ["), 0600); err != nil {
+		t.Fatal(err)
+	}
+	reloadConfig(path)
+	if _, found := currentConfig().Projects[1]; !found {
+		t.Error("broken config replaced the current one")
+	}
+}
+
+func TestConfigDiff(t *testing.T) {
+	old := config{Projects: map[int]*Project{
+		1: {Teams: map[string][]string{"Backend": {"alice", "bob"}, "QA": {"dave"}}},
+		2: {Teams: map[string][]string{"Backend": {"alice"}}},
+	}}
+	cfg := config{Projects: map[int]*Project{
+		1: {Teams: map[string][]string{"Backend": {"alice", "carol"}, "Frontend": {"erin"}}},
+		3: {Teams: map[string][]string{"Backend": {"alice"}}},
+	}}
+
+	want := []string{
+		"bob removed from team Backend of project 1",
+		"carol added to team Backend of project 1",
+		"project 2 removed",
+		"project 3 added",
+		"team Frontend added to project 1",
+		"team QA removed from project 1",
+	}
+	if got := configDiff(old, cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("diff = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// statusMarker starts the status note so it can be found and edited later,
+// GitLab doesn't render it
+const statusMarker = "<!-- ward:status -->"
+
+// statusNote renders votes, dislikes and unmet gates of MR as markdown.
+// Users are not mentioned as every edit of the note would notify them.
+func statusNote(cfg config, mr MergeRequest) string {
+	var teams []string
+	var note strings.Builder
+
+	note.WriteString(statusMarker + "\n")
+	if mr.ready() {
+		fmt.Fprintf(&note, "**Status:** :%v: ready to merge", cfg.Awards.Ready)
+	} else {
+		fmt.Fprintf(&note, "**Status:** :%v: not ready to merge", cfg.Awards.NotReady)
+	}
+
+	for team := range mr.Votes.Required {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+
+	if len(teams) > 0 {
+		note.WriteString("\n\n| Team | Votes | Voters |\n| --- | --- | --- |")
+		for _, team := range teams {
+			required := mr.Votes.Required[team]
+			voters := "-"
+			if len(mr.Votes.Voters[team]) > 0 {
+				voters = strings.Join(mr.Votes.Voters[team], ", ")
+			}
+			fmt.Fprintf(&note, "\n| %v | %v/%v | %v |",
+				team, required-mr.Votes.Missing[team], required, voters)
+		}
+	}
+
+	if len(mr.Votes.Dislikes) > 0 {
+		fmt.Fprintf(&note, "\n\nDisliked by: %v", strings.Join(mr.Votes.Dislikes, ", "))
+	}
+	if len(mr.Votes.Stale) > 0 {
+		fmt.Fprintf(&note, "\n\nReset by new commits: %v", strings.Join(mr.Votes.Stale, ", "))
+	}
+
+	if len(mr.Unmet) > 0 {
+		note.WriteString("\n\nUnmet conditions:")
+		for _, condition := range mr.Unmet {
+			fmt.Fprintf(&note, "\n- %v", condition)
+		}
+	}
+
+	return note.String()
+}
+
+// reportStatus keeps a single status note on open MRs up to date: it is
+// created once and edited afterwards. Projects with StatusNote get it on
+// every MR, projects with gates only once some gate is unmet.
+func reportStatus(cfg config, git gitClient, MRProjects map[int]MrProject) {
+	for pid, project := range MRProjects {
+		settings, found := cfg.Projects[pid]
+		if !found || !settings.StatusNote && !settings.Gates.enabled() {
+			continue
+		}
+
+		for mid, mr := range project.MR {
+			var status *gitlab.Note
+
+			notes, err := git.ListNotes(pid, mid)
+			if err != nil {
+				log.Printf("Failed to list notes for %v@%v: %v", mid, pid, err)
+				continue
+			}
+
+			for _, note := range notes {
+				if strings.ToLower(note.Author.Username) == git.Username() &&
+					strings.HasPrefix(note.Body, statusMarker) {
+					status = note
+					break
+				}
+			}
+
+			body := statusNote(cfg, mr)
+			switch {
+			case status == nil && (settings.StatusNote || len(mr.Unmet) > 0):
+				err = git.CreateNote(pid, mid, body)
+			case status != nil && status.Body != body:
+				err = git.UpdateNote(pid, mid, status.ID, body)
+			}
+			if err != nil {
+				log.Printf("Failed to post status for %v@%v: %v", mid, pid, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusNote(t *testing.T) {
+	cfg := testConfig()
+
+	var mr MergeRequest
+	mr.Votes = mrVotes{
+		Voters:   map[string][]string{"Backend": {"alice", "bob"}},
+		Required: map[string]int{"Backend": 2, "Frontend": 1},
+		Missing:  map[string]int{"Frontend": 1},
+		Dislikes: []string{"eve"},
+		Stale:    []string{"carol"},
+	}
+	mr.Awards.Like = false
+	mr.Awards.Dislike = true
+	mr.Unmet = []string{"pipeline is failed"}
+
+	want := statusMarker + "\n" +
+		"**Status:** :x: not ready to merge\n\n" +
+		"| Team | Votes | Voters |\n" +
+		"| --- | --- | --- |\n" +
+		"| Backend | 2/2 | alice, bob |\n" +
+		"| Frontend | 0/1 | - |\n\n" +
+		"Disliked by: eve\n\n" +
+		"Reset by new commits: carol\n\n" +
+		"Unmet conditions:\n" +
+		"- pipeline is failed"
+
+	got := statusNote(cfg, mr)
+	if got != want {
+		t.Errorf("status note:\n%v\nwant:\n%v", got, want)
+	}
+	if strings.Contains(got, "@") {
+		t.Errorf("status note mentions users: %v", got)
+	}
+}
+
+func TestReportStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		project Project
+		steps   [][]string
+		want    int
+	}{
+		{"disabled", Project{}, [][]string{{"pipeline is failed"}}, 0},
+		{"always shown", Project{StatusNote: true}, [][]string{nil, nil}, 1},
+		{"gates pass", Project{Gates: mrGates{Pipeline: true}}, [][]string{nil}, 0},
+		{"gates fail", Project{Gates: mrGates{Pipeline: true}}, [][]string{{"pipeline is failed"}, {"pipeline is running"}, nil}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Projects = map[int]*Project{1: &tt.project}
+			git := newFakeGit()
+			git.addNote(1, 1, "alice", "looks fine", time.Now())
+
+			var mr MergeRequest
+			for _, unmet := range tt.steps {
+				mr.Unmet = unmet
+				reportStatus(cfg, git, map[int]MrProject{1: {MR: map[int]MergeRequest{1: mr}}})
+			}
+
+			var status []string
+			for _, note := range git.notes[1][1] {
+				if strings.HasPrefix(note.Body, statusMarker) {
+					status = append(status, note.Body)
+				}
+			}
+			if len(status) != tt.want {
+				t.Fatalf("status notes = %v, want %v", len(status), tt.want)
+			}
+			if len(status) > 0 && status[0] != statusNote(cfg, mr) {
+				t.Errorf("status note was not updated: %v", status[0])
+			}
+		})
+	}
+}
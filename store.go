@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketActions    = []byte("actions")
+	bucketNotified   = []byte("notified")
+	bucketWatermarks = []byte("watermarks")
+)
+
+// history is the store shared by the scheduler and handlers, nil if disabled
+var history *store
+
+// store keeps MR decisions between runs. All methods are safe to call
+// on a nil store and do nothing then.
+type store struct {
+	db *bolt.DB
+}
+
+// mrRecord is an applied mrAction along with the time and outcome
+type mrRecord struct {
+	Time     time.Time
+	Pid      int
+	Mid      int
+	Award    string
+	State    bool
+	MergedBy string
+	Path     string
+	Votes    mrVotes
+	Outcome  string
+}
+
+func openStore(path string) (*store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open store %v: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketActions, bucketNotified, bucketWatermarks} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Failed to init store %v: %v", path, err)
+	}
+
+	return &store{db: db}, nil
+}
+
+func (s *store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func (s *store) addAction(action mrAction) error {
+	if s == nil {
+		return nil
+	}
+
+	record := mrRecord{
+		Time:     time.Now(),
+		Pid:      action.Pid,
+		Mid:      action.Mid,
+		Award:    action.Award,
+		State:    action.State,
+		MergedBy: action.MergedBy,
+		Path:     action.Path,
+		Votes:    action.Votes,
+		Outcome:  "approved",
+	}
+	if len(action.Votes.Missing) > 0 || len(action.Votes.Dislikes) > 0 {
+		record.Outcome = "rejected"
+	}
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketActions)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%v:%v:%020d", action.Pid, action.Mid, seq)
+		return b.Put([]byte(key), value)
+	})
+}
+
+// listActions returns records for the MR in order they were applied.
+// Zero mid lists all MRs of the project and zero pid lists everything.
+func (s *store) listActions(pid int, mid int) ([]mrRecord, error) {
+	var records []mrRecord
+
+	if s == nil {
+		return records, nil
+	}
+
+	var prefix string
+	if pid != 0 {
+		prefix = fmt.Sprintf("%v:", pid)
+		if mid != 0 {
+			prefix = fmt.Sprintf("%v%v:", prefix, mid)
+		}
+	}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketActions).Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, v = c.Next() {
+			var record mrRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+
+	return records, err
+}
+
+func (s *store) isNotified(pid int, mid int, kind string) bool {
+	var found bool
+
+	if s == nil {
+		return false
+	}
+
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		key := fmt.Sprintf("%v:%v:%v", pid, mid, kind)
+		found = tx.Bucket(bucketNotified).Get([]byte(key)) != nil
+		return nil
+	})
+
+	return found
+}
+
+func (s *store) setNotified(pid int, mid int, kind string) error {
+	if s == nil {
+		return nil
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		key := fmt.Sprintf("%v:%v:%v", pid, mid, kind)
+		return tx.Bucket(bucketNotified).Put([]byte(key), []byte(time.Now().Format(time.RFC3339)))
+	})
+}
+
+// clearNotified forgets the notification so it is sent again next time
+func (s *store) clearNotified(pid int, mid int, kind string) error {
+	if s == nil {
+		return nil
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		key := fmt.Sprintf("%v:%v:%v", pid, mid, kind)
+		return tx.Bucket(bucketNotified).Delete([]byte(key))
+	})
+}
+
+func (s *store) watermarks() (map[int]time.Time, error) {
+	marks := make(map[int]time.Time)
+
+	if s == nil {
+		return marks, nil
+	}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketWatermarks).ForEach(func(k, v []byte) error {
+			pid, err := strconv.Atoi(string(k))
+			if err != nil {
+				return err
+			}
+			mark, err := time.Parse(time.RFC3339, string(v))
+			if err != nil {
+				return err
+			}
+			marks[pid] = mark
+			return nil
+		})
+	})
+
+	return marks, err
+}
+
+func (s *store) setWatermark(pid int, mark time.Time) error {
+	if s == nil {
+		return nil
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		key := strconv.Itoa(pid)
+		return tx.Bucket(bucketWatermarks).Put([]byte(key), []byte(mark.Format(time.RFC3339)))
+	})
+}
+
+// ncMerge is a merge that failed requirements as reported by the audit
+type ncMerge struct {
+	Time     time.Time
+	Pid      int
+	Mid      int
+	Path     string
+	MergedBy string
+	Missing  map[string]int
+	Voters   map[string][]string
+	Dislikes []string
+	Disliked bool
+}
+
+// nonCompliant lists flagged merges for the project (all if zero) flagged
+// after since and merged by user if it is not empty.
+func (s *store) nonCompliant(pid int, since time.Time, user string) ([]ncMerge, error) {
+	var merges []ncMerge
+
+	records, err := s.listActions(pid, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if record.Award != "nc" || !record.State {
+			continue
+		}
+		if record.Time.Before(since) {
+			continue
+		}
+		if user != "" && !strings.EqualFold(record.MergedBy, user) {
+			continue
+		}
+
+		merges = append(merges, ncMerge{
+			Time:     record.Time,
+			Pid:      record.Pid,
+			Mid:      record.Mid,
+			Path:     record.Path,
+			MergedBy: record.MergedBy,
+			Missing:  record.Votes.Missing,
+			Voters:   record.Votes.Voters,
+			Dislikes: record.Votes.Dislikes,
+			Disliked: len(record.Votes.Dislikes) > 0,
+		})
+	}
+
+	return merges, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// useStore replaces history with a store in a temp dir for the test
+func useStore(t *testing.T) *store {
+	s, err := openStore(filepath.Join(t.TempDir(), "ward.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := history
+	history = s
+	t.Cleanup(func() {
+		history = saved
+		s.Close()
+	})
+
+	return s
+}
+
+func TestNotified(t *testing.T) {
+	s := useStore(t)
+
+	if s.isNotified(1, 2, "notready") {
+		t.Fatalf("notified before it was recorded")
+	}
+	if err := s.setNotified(1, 2, "notready"); err != nil {
+		t.Fatal(err)
+	}
+	if !s.isNotified(1, 2, "notready") || s.isNotified(1, 2, "nc") || s.isNotified(1, 3, "notready") {
+		t.Errorf("notification is recorded for wrong MR or kind")
+	}
+	if err := s.clearNotified(1, 2, "notready"); err != nil {
+		t.Fatal(err)
+	}
+	if s.isNotified(1, 2, "notready") {
+		t.Errorf("notification was not cleared")
+	}
+}
+
+func TestProcessMRNotifiesAgain(t *testing.T) {
+	useStore(t)
+	cfg := testConfig()
+	cfg.Projects = map[int]*Project{1: {Teams: map[string][]string{"Backend": {"alice", "bob"}}}}
+	git := newFakeGit()
+	notReady := mrAction{Pid: 1, Mid: 1, Award: "notready", State: true, Votes: mrVotes{Missing: map[string]int{"Backend": 1}}}
+
+	processMR(cfg, git, []mrAction{notReady})
+	processMR(cfg, git, []mrAction{notReady})
+	if got := len(git.notes[1][1]); got != 1 {
+		t.Fatalf("%v notes, want 1", got)
+	}
+
+	processMR(cfg, git, []mrAction{{Pid: 1, Mid: 1, Award: "ready", State: true}})
+	processMR(cfg, git, []mrAction{notReady})
+	if got := len(git.notes[1][1]); got != 2 {
+		t.Errorf("%v notes, want 2 after MR was ready again", got)
+	}
+}
+
+func TestListActions(t *testing.T) {
+	s := useStore(t)
+
+	for _, action := range []mrAction{
+		{Pid: 1, Mid: 1, Award: "notready", State: true, Votes: mrVotes{Missing: map[string]int{"Backend": 1}}},
+		{Pid: 1, Mid: 1, Award: "ready", State: true},
+		{Pid: 1, Mid: 10, Award: "ready", State: true},
+		{Pid: 2, Mid: 1, Award: "nc", State: true},
+	} {
+		if err := s.addAction(action); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Records are ordered by MR key, then as applied
+	tests := []struct {
+		pid  int
+		mid  int
+		want []string
+	}{
+		{1, 1, []string{"1:1:notready:rejected", "1:1:ready:approved"}},
+		{1, 10, []string{"1:10:ready:approved"}},
+		{1, 0, []string{"1:10:ready:approved", "1:1:notready:rejected", "1:1:ready:approved"}},
+		{0, 0, []string{"1:10:ready:approved", "1:1:notready:rejected", "1:1:ready:approved", "2:1:nc:approved"}},
+		{3, 0, nil},
+	}
+
+	for _, tt := range tests {
+		records, err := s.listActions(tt.pid, tt.mid)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, record := range records {
+			got = append(got, fmt.Sprintf("%v:%v:%v:%v", record.Pid, record.Mid, record.Award, record.Outcome))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("listActions(%v, %v) = %v, want %v", tt.pid, tt.mid, got, tt.want)
+		}
+	}
+}
+
+func TestNonCompliant(t *testing.T) {
+	s := useStore(t)
+	missing := mrVotes{Missing: map[string]int{"Backend": 1}, Dislikes: []string{"carol"}}
+
+	for _, action := range []mrAction{
+		{Pid: 1, Mid: 1, Award: "nc", State: true, MergedBy: "alice", Votes: missing},
+		{Pid: 1, Mid: 2, Award: "nc", State: true, MergedBy: "Bob"},
+		{Pid: 1, Mid: 2, Award: "nc", State: false},
+		{Pid: 1, Mid: 3, Award: "ready", State: true},
+		{Pid: 2, Mid: 1, Award: "nc", State: true, MergedBy: "alice"},
+	} {
+		if err := s.addAction(action); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name  string
+		pid   int
+		since time.Time
+		user  string
+		want  []string
+	}{
+		{"all", 0, time.Time{}, "", []string{"1:1", "1:2", "2:1"}},
+		{"project", 1, time.Time{}, "", []string{"1:1", "1:2"}},
+		{"since past", 0, past, "", []string{"1:1", "1:2", "2:1"}},
+		{"since future", 0, time.Now().Add(time.Hour), "", nil},
+		{"user", 0, time.Time{}, "bob", []string{"1:2"}},
+		{"all filters", 2, past, "alice", []string{"2:1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merges, err := s.nonCompliant(tt.pid, tt.since, tt.user)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, merge := range merges {
+				got = append(got, fmt.Sprintf("%v:%v", merge.Pid, merge.Mid))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("merges = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	merges, err := s.nonCompliant(1, time.Time{}, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merges) != 1 || !merges[0].Disliked || merges[0].Missing["Backend"] != 1 {
+		t.Errorf("merge details are lost: %+v", merges)
+	}
+}
+
+func TestWatermarks(t *testing.T) {
+	s := useStore(t)
+	mark := time.Date(2020, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	if err := s.setWatermark(1, mark); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.setWatermark(2, mark.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.setWatermark(1, mark.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	marks, err := s.watermarks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(marks) != 2 || !marks[1].Equal(mark.Add(time.Minute)) || !marks[2].Equal(mark.Add(time.Hour)) {
+		t.Errorf("watermarks = %v", marks)
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	var s *store
+
+	if err := s.addAction(mrAction{Pid: 1, Mid: 1}); err != nil {
+		t.Error(err)
+	}
+	if records, err := s.listActions(0, 0); err != nil || len(records) != 0 {
+		t.Errorf("listActions = %v, %v", records, err)
+	}
+	if marks, err := s.watermarks(); err != nil || len(marks) != 0 {
+		t.Errorf("watermarks = %v, %v", marks, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 	"log"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,13 +16,20 @@ type MrProject struct {
 	Name string
 	Path string
 	MR   map[int]MergeRequest
+	// Updated is updated_at of the last MR listed
+	Updated time.Time
 }
 
 type MergeRequest struct {
 	Name     string
 	Path     string
 	MergedBy string
-	Awards   struct {
+	Activity time.Time
+	Draft    bool
+	Votes    mrVotes
+	// Unmet lists gates MR doesn't pass
+	Unmet  []string
+	Awards struct {
 		Like         bool
 		Dislike      bool
 		Ready        int
@@ -30,6 +38,21 @@ type MergeRequest struct {
 	}
 }
 
+// ready tells if MR has enough votes, no dislikes and passes all gates
+func (mr MergeRequest) ready() bool {
+	return mr.Awards.Like && !mr.Awards.Dislike && len(mr.Unmet) == 0
+}
+
+// mrVotes describes who voted for MR and which teams still owe votes
+type mrVotes struct {
+	Voters   map[string][]string
+	Required map[string]int
+	Missing  map[string]int
+	Dislikes []string
+	// Stale are likes given before the latest push which no longer count
+	Stale []string
+}
+
 type mrAction struct {
 	Pid      int
 	Mid      int
@@ -38,11 +61,18 @@ type mrAction struct {
 	MergedBy string
 	Path     string
 	State    bool
+	Votes    mrVotes
+	// Draft MRs don't ping reviewers
+	Draft bool
 }
 
 type deadBranch struct {
-	Author string
-	Age    int
+	Author   string
+	Age      int
+	Commit   string
+	Tier     string
+	FinalAt  time.Time
+	DeleteAt time.Time
 }
 
 type deadProject struct {
@@ -50,12 +80,35 @@ type deadProject struct {
 	URL      string
 	Owners   []string
 	Branches map[string]deadBranch
+	Merged   map[string]deadBranch
+	// Archived holds tags of deleted dead branches
+	Archived map[string]string
+}
+
+// stalledMR is an open MR whose source branch has no updates. Reviewers
+// are members of teams which still owe votes.
+type stalledMR struct {
+	Branch    string
+	Title     string
+	URL       string
+	Age       int
+	Reviewers map[string][]string
 }
 
 type deadAuthor struct {
 	Name     string
 	Branches map[int][]string
+	Merged   map[int][]string
+	Stalled  map[int][]stalledMR
 	Projects map[int]deadProject
+	DryRun   bool
+	// KeepLabel exempts source branches of open MRs from deletion
+	KeepLabel string
+}
+
+// empty reports if there is nothing to tell the author about
+func (a deadAuthor) empty() bool {
+	return len(a.Branches) == 0 && len(a.Merged) == 0 && len(a.Stalled) == 0
 }
 
 type deadResults struct {
@@ -63,17 +116,12 @@ type deadResults struct {
 	Authors  map[string]deadAuthor
 }
 
-func checkPrjRequests(cfg config, projects map[int]*Project, list string) (map[int]MrProject, error) {
+// checkPrjRequests walks all pages of MRs for the projects. If since holds
+// a watermark for the project, only MRs updated after it are listed.
+func checkPrjRequests(cfg config, git gitClient, projects map[int]*Project, list string, since map[int]time.Time) (map[int]MrProject, error) {
 	var mrs_opts *gitlab.ListProjectMergeRequestsOptions
 	MrProjects := make(map[int]MrProject)
 
-	git_opts := gitlab.WithBaseURL(cfg.Endpoints.GitLab)
-	git, err := gitlab.NewBasicAuthClient(
-		cfg.Credentials.User, cfg.Credentials.Password, git_opts)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to connect to GitLab: %v", err)
-	}
-
 	switch list {
 	case "opened":
 		mrs_opts = &gitlab.ListProjectMergeRequestsOptions{
@@ -82,17 +130,19 @@ func checkPrjRequests(cfg config, projects map[int]*Project, list string) (map[i
 			Scope:   gitlab.String("all"),
 			WIP:     gitlab.String("no"),
 			ListOptions: gitlab.ListOptions{
-				PerPage: 10,
+				PerPage: 100,
 				Page:    1,
 			},
 		}
 	case "merged":
+		// Oldest first, so the watermark can follow MRs checked within limit
 		mrs_opts = &gitlab.ListProjectMergeRequestsOptions{
 			State:   gitlab.String("merged"),
 			OrderBy: gitlab.String("updated_at"),
+			Sort:    gitlab.String("asc"),
 			Scope:   gitlab.String("all"),
 			ListOptions: gitlab.ListOptions{
-				PerPage: 10,
+				PerPage: 100,
 				Page:    1,
 			},
 		}
@@ -101,7 +151,7 @@ func checkPrjRequests(cfg config, projects map[int]*Project, list string) (map[i
 			OrderBy: gitlab.String("updated_at"),
 			Scope:   gitlab.String("all"),
 			ListOptions: gitlab.ListOptions{
-				PerPage: 10,
+				PerPage: 100,
 				Page:    1,
 			},
 		}
@@ -109,119 +159,251 @@ func checkPrjRequests(cfg config, projects map[int]*Project, list string) (map[i
 
 	// Process projects
 	for pid, project := range projects {
-		var MrPrj MrProject
-		var consensus int
-
-		if project.Votes > 0 {
-			consensus = project.Votes
-		} else {
-			if len(project.Teams) < 2 {
-				consensus = 2
-			} else {
-				consensus = 1
-			}
-		}
-
 		// Get the list of protected branches
-		pbs_opts := &gitlab.ListProtectedBranchesOptions{}
-		pbs, _, err := git.ProtectedBranches.ListProtectedBranches(pid, pbs_opts)
+		protected_branches, err := git.ListProtectedBranches(pid)
 		if err != nil {
 			log.Printf("Failed to get list of protected branches for %v: %v", pid, err)
 			continue
 		}
-		var protected_branches []string
-		for _, pb := range pbs {
-			protected_branches = append(protected_branches, pb.Name)
+
+		prj_opts := *mrs_opts
+		if project.Gates.Draft {
+			// Drafts are listed to be reported as not ready
+			prj_opts.WIP = nil
+		}
+		if updated, found := since[pid]; found && !updated.IsZero() {
+			prj_opts.UpdatedAfter = gitlab.Time(updated)
+		}
+
+		MrPrj, err := listRequests(cfg, git, project, pid, protected_branches, &prj_opts)
+		if err != nil {
+			log.Println(err)
+			continue
 		}
 
+		MrProjects[pid] = MrPrj
+	}
+
+	return MrProjects, nil
+}
+
+// listRequests processes all pages of MRs for the project not just latest
+func listRequests(cfg config, git gitClient, project *Project, pid int, protected_branches []string,
+	mrs_opts *gitlab.ListProjectMergeRequestsOptions) (MrProject, error) {
+	var MrPrj MrProject
+	var count int
+	var skipped bool
+
+	for {
 		// Get Merge Requests for project
-		mrs, _, err := git.MergeRequests.ListProjectMergeRequests(pid, mrs_opts)
+		mrs, nextPage, err := git.ListMergeRequests(pid, mrs_opts)
 		if err != nil {
-			log.Printf("Failed to list Merge Requests for %v: %v", pid, err)
-			break
+			return MrPrj, fmt.Errorf("Failed to list Merge Requests for %v: %v", pid, err)
 		}
 
 		// Process Merge Requests
 		for _, mr := range mrs {
 			// Ignore MR if target branch is not protected
 			if !contains(protected_branches, mr.TargetBranch) {
+				if mr.UpdatedAt != nil && !skipped {
+					MrPrj.Updated = *mr.UpdatedAt
+				}
 				continue
 			}
 
-			var MRequest MergeRequest
-			likes := make(map[string]int)
-
-			awards, _, err := git.AwardEmoji.ListMergeRequestAwardEmoji(pid, mr.IID, &gitlab.ListAwardEmojiOptions{})
+			// A broken MR doesn't stop the rest, Updated stays before it
+			MRequest, err := checkRequest(cfg, git, project, pid, mr)
 			if err != nil {
-				log.Printf("Failed to list MR awards: %v", err)
-				break
+				log.Printf("Failed to check MR %v@%v: %v", mr.IID, pid, err)
+				skipped = true
+				continue
+			}
+			if mr.UpdatedAt != nil && !skipped {
+				MrPrj.Updated = *mr.UpdatedAt
 			}
 
-			// Process awards
-			for _, award := range awards {
-				// Check group awards
-				if award.User.Username != mr.Author.Username {
-					switch award.Name {
-					case cfg.Awards.Like:
-						for team, members := range project.Teams {
-							if likes[team] < consensus {
-								if contains(members, strings.ToLower(award.User.Username)) {
-									likes[team]++
-								}
-							}
-						}
-					case cfg.Awards.Dislike:
-						MRequest.Awards.Dislike = true
-					}
-				}
-
-				// Check service awards
-				if strings.ToLower(award.User.Username) == cfg.Credentials.User {
-					switch award.Name {
-					case cfg.Awards.Ready:
-						MRequest.Awards.Ready = award.ID
-					case cfg.Awards.NotReady:
-						MRequest.Awards.NotReady = award.ID
-					case cfg.Awards.NonCompliant:
-						MRequest.Awards.NonCompliant = award.ID
-						MRequest.MergedBy = mr.MergedBy.Username
-					}
-				}
+			if MrPrj.MR == nil {
+				MrPrj.MR = make(map[int]MergeRequest)
 			}
+			MrPrj.MR[mr.IID] = MRequest
 
-			// Deside if MR meets Likes requirement
-			mrLike := true
-			for tid := range project.Teams {
-				if mrLike {
-					if v, found := likes[tid]; found {
-						if v < consensus {
-							mrLike = false
-							break
-						}
-					} else {
-						mrLike = false
-						break
-					}
-				}
+			count++
+			if cfg.Limits.MergeRequests > 0 && count >= cfg.Limits.MergeRequests {
+				log.Printf("Limit of %v MRs reached for %v", count, pid)
+				return MrPrj, nil
 			}
-			MRequest.Awards.Like = mrLike
+		}
+
+		if nextPage == 0 {
+			return MrPrj, nil
+		}
+		mrs_opts.Page = nextPage
+	}
+}
 
-			MRequest.Path = mr.WebURL
+// checkPrjRequest evaluates a single MR the same way checkPrjRequests does
+// for a whole project. It returns the MR state along with the results.
+func checkPrjRequest(cfg config, git gitClient, pid int, mid int) (map[int]MrProject, string, error) {
+	var MrPrj MrProject
+	MrProjects := make(map[int]MrProject)
+
+	project, found := cfg.Projects[pid]
+	if !found {
+		return MrProjects, "", fmt.Errorf("Project %v is not configured", pid)
+	}
+
+	mr, err := git.GetMergeRequest(pid, mid)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to get Merge Request %v@%v: %v", mid, pid, err)
+	}
 
-			if mr.MergedBy != nil {
+	// Drafts are skipped by the opened MR listing as well
+	if mr.State == "opened" && mr.WorkInProgress && !project.Gates.Draft {
+		return MrProjects, mr.State, nil
+	}
+
+	protected_branches, err := git.ListProtectedBranches(pid)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to get list of protected branches for %v: %v", pid, err)
+	}
+
+	// Ignore MR if target branch is not protected
+	if !contains(protected_branches, mr.TargetBranch) {
+		return MrProjects, mr.State, nil
+	}
+
+	MRequest, err := checkRequest(cfg, git, project, pid, mr)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to list MR awards: %v", err)
+	}
+
+	MrPrj.MR = map[int]MergeRequest{mr.IID: MRequest}
+	MrProjects[pid] = MrPrj
+
+	return MrProjects, mr.State, nil
+}
+
+func checkRequest(cfg config, git gitClient, project *Project, pid int, mr *gitlab.MergeRequest) (MergeRequest, error) {
+	var MRequest MergeRequest
+	likes := make(map[string]int)
+	MRequest.Votes.Voters = make(map[string][]string)
+	MRequest.Votes.Required = make(map[string]int)
+	MRequest.Votes.Missing = make(map[string]int)
+
+	teams := requiredTeams(git, project, pid, mr)
+	project = project.forBranch(mr.TargetBranch)
+
+	awards, err := git.ListAwards(pid, mr.IID)
+	if err != nil {
+		return MRequest, err
+	}
+
+	var pushed time.Time
+	if project.ResetVotesOnPush {
+		version, err := git.LatestVersion(pid, mr.IID)
+		if err != nil {
+			return MRequest, err
+		}
+		if version != nil && version.CreatedAt != nil {
+			pushed = *version.CreatedAt
+		}
+	}
+
+	if mr.CreatedAt != nil {
+		MRequest.Activity = *mr.CreatedAt
+	}
+
+	// Process awards
+	for _, award := range awards {
+		if award.CreatedAt != nil && award.CreatedAt.After(MRequest.Activity) &&
+			strings.ToLower(award.User.Username) != git.Username() {
+			MRequest.Activity = *award.CreatedAt
+		}
+
+		// Check service awards
+		if strings.ToLower(award.User.Username) == git.Username() {
+			switch award.Name {
+			case cfg.Awards.Ready:
+				MRequest.Awards.Ready = award.ID
+			case cfg.Awards.NotReady:
+				MRequest.Awards.NotReady = award.ID
+			case cfg.Awards.NonCompliant:
+				MRequest.Awards.NonCompliant = award.ID
 				MRequest.MergedBy = mr.MergedBy.Username
 			}
+		}
+	}
 
-			if MrPrj.MR == nil {
-				MrPrj.MR = make(map[int]MergeRequest)
+	votes, err := collectVotes(cfg, git, pid, mr.IID, awards)
+	if err != nil {
+		return MRequest, err
+	}
+
+	// Check group votes
+	for _, vote := range votes {
+		if strings.EqualFold(vote.User, mr.Author.Username) {
+			continue
+		}
+
+		if !vote.Like {
+			MRequest.Awards.Dislike = true
+			MRequest.Votes.Dislikes = append(MRequest.Votes.Dislikes, vote.User)
+			continue
+		}
+
+		// Votes of unknown time don't survive a push either
+		if !pushed.IsZero() && (vote.Time.IsZero() || vote.Time.Before(pushed)) {
+			MRequest.Votes.Stale = append(MRequest.Votes.Stale, vote.User)
+			continue
+		}
+		for team, members := range project.Teams {
+			if contains(members, strings.ToLower(vote.User)) {
+				MRequest.Votes.Voters[team] = append(MRequest.Votes.Voters[team], vote.User)
+				if likes[team] < project.consensus(team) {
+					likes[team]++
+				}
 			}
-			MrPrj.MR[mr.IID] = MRequest
 		}
+	}
 
-		MrProjects[pid] = MrPrj
+	// Deside if MR meets Likes requirement
+	mrLike := true
+	for tid := range teams {
+		if mrLike {
+			if v, found := likes[tid]; found {
+				if v < project.consensus(tid) {
+					mrLike = false
+					break
+				}
+			} else {
+				mrLike = false
+				break
+			}
+		}
 	}
+	MRequest.Awards.Like = mrLike
 
-	return MrProjects, nil
+	for team := range teams {
+		consensus := project.consensus(team)
+		MRequest.Votes.Required[team] = consensus
+		if likes[team] < consensus {
+			MRequest.Votes.Missing[team] = consensus - likes[team]
+		}
+	}
+
+	if mr.State == "opened" {
+		MRequest.Unmet = checkGates(git, project, pid, mr)
+	}
+
+	MRequest.Name = mr.Title
+	MRequest.Path = mr.WebURL
+	MRequest.Draft = mr.WorkInProgress
+
+	if mr.MergedBy != nil {
+		MRequest.MergedBy = mr.MergedBy.Username
+	}
+
+	return MRequest, nil
 }
 
 func evalOpenedRequests(MRProjects map[int]MrProject) []mrAction {
@@ -229,14 +411,15 @@ func evalOpenedRequests(MRProjects map[int]MrProject) []mrAction {
 
 	for pid, project := range MRProjects {
 		for mid, mr := range project.MR {
-			if mr.Awards.Like && !mr.Awards.Dislike {
+			if mr.ready() {
 				if mr.Awards.NotReady != 0 {
 					action := mrAction{
 						Pid:   pid,
 						Mid:   mid,
 						Aid:   mr.Awards.NotReady,
 						Award: "notready",
-						State: false}
+						State: false,
+						Votes: mr.Votes}
 					actions = append(actions, action)
 				}
 				if mr.Awards.Ready == 0 {
@@ -245,7 +428,8 @@ func evalOpenedRequests(MRProjects map[int]MrProject) []mrAction {
 						Mid:   mid,
 						Aid:   mr.Awards.Ready,
 						Award: "ready",
-						State: true}
+						State: true,
+						Votes: mr.Votes}
 					actions = append(actions, action)
 				}
 			} else {
@@ -255,7 +439,8 @@ func evalOpenedRequests(MRProjects map[int]MrProject) []mrAction {
 						Mid:   mid,
 						Aid:   mr.Awards.Ready,
 						Award: "ready",
-						State: false}
+						State: false,
+						Votes: mr.Votes}
 					actions = append(actions, action)
 				}
 				if mr.Awards.NotReady == 0 {
@@ -265,7 +450,9 @@ func evalOpenedRequests(MRProjects map[int]MrProject) []mrAction {
 						Aid:      mr.Awards.NotReady,
 						Award:    "notready",
 						MergedBy: mr.MergedBy,
-						State:    true}
+						State:    true,
+						Votes:    mr.Votes,
+						Draft:    mr.Draft}
 					actions = append(actions, action)
 				}
 			}
@@ -276,7 +463,8 @@ func evalOpenedRequests(MRProjects map[int]MrProject) []mrAction {
 					Mid:   mid,
 					Aid:   mr.Awards.NonCompliant,
 					Award: "nc",
-					State: false}
+					State: false,
+					Votes: mr.Votes}
 				actions = append(actions, action)
 			}
 		}
@@ -299,7 +487,8 @@ func evalMergedRequests(MRProjects map[int]MrProject) []mrAction {
 						Award:    "nc",
 						MergedBy: mr.MergedBy,
 						Path:     mr.Path,
-						State:    true}
+						State:    true,
+						Votes:    mr.Votes}
 					actions = append(actions, action)
 				}
 			} else {
@@ -309,7 +498,8 @@ func evalMergedRequests(MRProjects map[int]MrProject) []mrAction {
 						Mid:   mid,
 						Aid:   mr.Awards.NonCompliant,
 						Award: "nc",
-						State: false}
+						State: false,
+						Votes: mr.Votes}
 					actions = append(actions, action)
 				}
 			}
@@ -320,7 +510,8 @@ func evalMergedRequests(MRProjects map[int]MrProject) []mrAction {
 					Mid:   mid,
 					Aid:   mr.Awards.NotReady,
 					Award: "notready",
-					State: false}
+					State: false,
+					Votes: mr.Votes}
 				actions = append(actions, action)
 			}
 
@@ -330,7 +521,8 @@ func evalMergedRequests(MRProjects map[int]MrProject) []mrAction {
 					Mid:   mid,
 					Aid:   mr.Awards.Ready,
 					Award: "ready",
-					State: false}
+					State: false,
+					Votes: mr.Votes}
 				actions = append(actions, action)
 			}
 		}
@@ -339,36 +531,54 @@ func evalMergedRequests(MRProjects map[int]MrProject) []mrAction {
 	return actions
 }
 
-func processMR(cfg config, actions []mrAction) {
+// processMR applies actions and returns projects where some of them failed
+func processMR(cfg config, git gitClient, actions []mrAction) map[int]bool {
+	failed := make(map[int]bool)
 	award := map[string]string{
 		"ready":    cfg.Awards.Ready,
 		"notready": cfg.Awards.NotReady,
 		"nc":       cfg.Awards.NonCompliant,
 	}
-
-	git_opts := gitlab.WithBaseURL(cfg.Endpoints.GitLab)
-	git, err := gitlab.NewBasicAuthClient(
-		cfg.Credentials.User, cfg.Credentials.Password, git_opts)
-	if err != nil {
-		fmt.Printf("Failed to connect to GitLab: %v", err)
-	}
+	// MRs which lost Ready award with a note mentioning reviewers already
+	invalidated := make(map[string]bool)
 
 	for _, action := range actions {
 		if action.State {
-			award_opts := &gitlab.CreateAwardEmojiOptions{Name: award[action.Award]}
-			_, _, _ = git.AwardEmoji.CreateMergeRequestAwardEmoji(action.Pid, action.Mid, award_opts)
+			err := git.CreateAward(action.Pid, action.Mid, award[action.Award])
+			if err == nil {
+				if err := history.addAction(action); err != nil {
+					log.Printf("Failed to record action for %v@%v: %v", action.Mid, action.Pid, err)
+				}
+			} else {
+				log.Printf("Failed to award %v@%v: %v", action.Mid, action.Pid, err)
+				failed[action.Pid] = true
+			}
+
+			// Reviewers are notified again if MR loses readiness later
+			if action.Award == "ready" && err == nil {
+				if err := history.clearNotified(action.Pid, action.Mid, "notready"); err != nil {
+					log.Printf("Failed to reset notification for %v@%v: %v", action.Mid, action.Pid, err)
+				}
+			}
 
 			// Notify reviewers (most likely onece per MR)
-			if action.Award == "notready" {
-				err := notifyReviewers(git, cfg.Projects[action.Pid].Teams, action.Pid, action.Mid)
+			if action.Award == "notready" && !action.Draft &&
+				!history.isNotified(action.Pid, action.Mid, action.Award) {
+				// Invalidation note of the same MR has mentioned them already
+				var err error
+				if !invalidated[fmt.Sprintf("%v:%v", action.Pid, action.Mid)] {
+					err = notifyReviewers(git, cfg.Projects[action.Pid].Teams, action.Votes, action.Pid, action.Mid)
+				}
 				if err != nil {
 					log.Printf("Failed to post notification message for %v@%v: %v",
 						action.Mid, action.Pid, err)
+				} else if err := history.setNotified(action.Pid, action.Mid, action.Award); err != nil {
+					log.Printf("Failed to record notification for %v@%v: %v", action.Mid, action.Pid, err)
 				}
 			}
 
 			// Notify about non-compiant merge
-			if action.Award == "nc" {
+			if action.Award == "nc" && !history.isNotified(action.Pid, action.Mid, action.Award) {
 				var prj_name string
 				var prj_url string
 				var users []string
@@ -378,8 +588,7 @@ func processMR(cfg config, actions []mrAction) {
 				var ownersEmail []string
 				var ownersUsers []string
 
-				prj_opts := &gitlab.GetProjectOptions{}
-				prj, _, err := git.Projects.GetProject(action.Pid, prj_opts)
+				prj, err := git.GetProject(action.Pid)
 				if err != nil {
 					prj_name = fmt.Sprintf("%v", action.Pid)
 					prj_url = cfg.Endpoints.GitLab
@@ -421,14 +630,39 @@ func processMR(cfg config, actions []mrAction) {
 				if err := mailSend(cfg, ownersEmail, subj, msg); err != nil {
 					log.Printf("Failed to send mail to owners: %v", err)
 				}
+
+				if err := history.setNotified(action.Pid, action.Mid, action.Award); err != nil {
+					log.Printf("Failed to record notification for %v@%v: %v", action.Mid, action.Pid, err)
+				}
 			}
 		} else {
-			_, _ = git.AwardEmoji.DeleteMergeRequestAwardEmoji(action.Pid, action.Mid, action.Aid)
+			err := git.DeleteAward(action.Pid, action.Mid, action.Aid)
+			if err == nil {
+				if err := history.addAction(action); err != nil {
+					log.Printf("Failed to record action for %v@%v: %v", action.Mid, action.Pid, err)
+				}
+
+				// Approvals are gone with new commits
+				if action.Award == "ready" && len(action.Votes.Stale) > 0 {
+					err := notifyInvalidated(git, cfg.Projects[action.Pid].Teams, action.Votes, action.Pid, action.Mid)
+					if err != nil {
+						log.Printf("Failed to post invalidation message for %v@%v: %v",
+							action.Mid, action.Pid, err)
+					} else {
+						invalidated[fmt.Sprintf("%v:%v", action.Pid, action.Mid)] = true
+					}
+				}
+			} else {
+				log.Printf("Failed to remove award from %v@%v: %v", action.Mid, action.Pid, err)
+				failed[action.Pid] = true
+			}
 		}
 	}
+
+	return failed
 }
 
-func detectDead(cfg config) deadResults {
+func detectDead(cfg config, git gitClient) deadResults {
 	var undead deadResults
 	undead.Authors = make(map[string]deadAuthor)
 	undead.Projects = make(map[int]deadProject)
@@ -438,133 +672,476 @@ func detectDead(cfg config) deadResults {
 
 	now := time.Now()
 
-	git_opts := gitlab.WithBaseURL(cfg.Endpoints.GitLab)
-	git, err := gitlab.NewBasicAuthClient(
-		cfg.Credentials.User, cfg.Credentials.Password, git_opts)
-	if err != nil {
-		fmt.Printf("Failed to connect to GitLab: %v", err)
-	}
-
-	branches_opts := &gitlab.ListBranchesOptions{
-		ListOptions: gitlab.ListOptions{
-			PerPage: 10,
-			Page:    1,
-		},
-	}
-
-	for pid, project := range projects {
+	for pid, settings := range projects {
 		var owners []string
-		for _, team := range project.Teams {
+		tiers := cfg.tiers(pid)
+
+		branches_opts := &gitlab.ListBranchesOptions{
+			ListOptions: gitlab.ListOptions{
+				PerPage: 10,
+				Page:    1,
+			},
+		}
+		for _, team := range settings.Teams {
 			owners = append(owners, team...)
 		}
 
+		// Branches of open MRs with the keep label are exempt
+		opened, err := listOpenRequests(git, pid)
+		if err != nil {
+			log.Printf("Failed to list Merge Requests for %v: %v", pid, err)
+			continue
+		}
+
 		// Process all branches for the project not just latest
 		for {
-			branches, response, err := git.Branches.ListBranches(pid, branches_opts)
+			branches, nextPage, err := git.ListBranches(pid, branches_opts)
 			if err != nil {
 				log.Printf("Failed to list branches: %v", err)
 				break
 			}
 
 			for _, branch := range branches {
-				var name string
-				var mail string
-
 				// Ignore protected branches
 				if branch.Protected {
 					continue
 				}
 
+				// Ignore exempt branches
+				if matchAny(settings.KeepBranches, branch.Name) ||
+					hasLabel(opened[branch.Name], cfg.keepLabel()) {
+					continue
+				}
+
 				updated := *branch.Commit.AuthoredDate
-				if now.Sub(updated).Hours() >= 7*24 {
-					if _, found := trueMail[branch.Commit.AuthorEmail]; !found {
-						// Validate true mail
-						if ldapCheck(cfg, branch.Commit.AuthorEmail) {
-							name = branch.Commit.AuthorName
-							mail = branch.Commit.AuthorEmail
-						} else {
-							var rcptUsers []string
-
-							rcptUser := strings.Split(branch.Commit.AuthorEmail, "@")
-							rcptUsers = append(rcptUsers, rcptUser[0])
-							rcptEmails := ldapMail(cfg, rcptUsers)
-
-							if len(rcptEmails) > 0 {
-								name = branch.Commit.AuthorName
-								mail = rcptEmails[0]
-							} else {
-								rcptEmails := ldapMail(cfg, []string{branch.Commit.AuthorName})
-								if len(rcptEmails) > 0 {
-									name = branch.Commit.AuthorName
-									mail = rcptEmails[0]
-								} else {
-									name = "Unidentified"
-									mail = "unidentified@any.local"
-									log.Printf("Unidentified author: %v - %v",
-										branch.Commit.AuthorName, branch.Commit.AuthorEmail)
-								}
-
-							}
+				age := now.Sub(updated)
+
+				// Branches in review are not dead but their MRs are stalled
+				if mrs := opened[branch.Name]; len(mrs) > 0 {
+					if tiers.tier(age) != "" {
+						mail := undead.author(cfg, trueMail, branch.Commit)
+						for _, mr := range mrs {
+							undead.Authors[mail].Stalled[pid] = append(undead.Authors[mail].Stalled[pid], stalledMR{
+								Branch:    branch.Name,
+								Title:     mr.Title,
+								URL:       mr.WebURL,
+								Age:       int(age.Hours()) / 24,
+								Reviewers: pendingReviewers(cfg, git, settings, pid, mr),
+							})
 						}
+						undead.project(cfg, git, pid, owners)
+					}
+					continue
+				}
 
-						trueMail[branch.Commit.AuthorEmail] = mail
-
-						if _, found := undead.Authors[mail]; !found {
-							undead.Authors[mail] = deadAuthor{
-								Name:     name,
-								Branches: make(map[int][]string),
-							}
+				// Merged branches are wiped after grace period since the merge,
+				// which can't be earlier than the tip commit
+				grace := time.Duration(settings.DeleteMergedAfterDays) * 24 * time.Hour
+				if settings.DeleteMergedAfterDays > 0 && age >= grace {
+					merged, found := mergedAt(git, pid, branch)
+					if found && now.Sub(merged) >= grace {
+						mail := undead.author(cfg, trueMail, branch.Commit)
+						undead.Authors[mail].Merged[pid] = append(undead.Authors[mail].Merged[pid], branch.Name)
+
+						undead.project(cfg, git, pid, owners).Merged[branch.Name] = deadBranch{
+							Age:    int(now.Sub(merged).Hours()) / 24,
+							Author: branch.Commit.AuthorName,
+							Commit: branch.Commit.ID,
 						}
-					} else {
-						mail = trueMail[branch.Commit.AuthorEmail]
+						continue
 					}
-					undead.Authors[mail].Branches[pid] = append(undead.Authors[mail].Branches[pid], branch.Name)
+					if found {
+						continue
+					}
+				}
 
-					// Fill in data for a the project
-					if _, found := undead.Projects[pid]; !found {
-						var prj_name string
-						var prj_url string
-
-						prj_opts := &gitlab.GetProjectOptions{}
-						prj, _, err := git.Projects.GetProject(pid, prj_opts)
-						if err != nil {
-							prj_name = fmt.Sprintf("%v", pid)
-							prj_url = cfg.Endpoints.GitLab
-							log.Printf("Failed to get project info: %v", err)
-						} else {
-							prj_name = prj.NameWithNamespace
-							prj_url = prj.WebURL
-						}
+				if tier := tiers.tier(age); tier != "" {
+					mail := undead.author(cfg, trueMail, branch.Commit)
+					undead.Authors[mail].Branches[pid] = append(undead.Authors[mail].Branches[pid], branch.Name)
 
-						undead.Projects[pid] = deadProject{
-							Branches: make(map[string]deadBranch),
-							Owners:   owners,
-							URL:      prj_url,
-							Name:     prj_name,
-						}
-					}
-					undead.Projects[pid].Branches[branch.Name] = deadBranch{
-						Age:    int(now.Sub(updated).Hours()) / 24,
+					dead := deadBranch{
+						Age:    int(age.Hours()) / 24,
 						Author: branch.Commit.AuthorName,
+						Commit: branch.Commit.ID,
+						Tier:   tier,
 					}
+					if tiers.Final > 0 {
+						dead.FinalAt = updated.AddDate(0, 0, tiers.Final)
+					}
+					if tiers.Delete > 0 {
+						dead.DeleteAt = updated.AddDate(0, 0, tiers.Delete)
+					}
+					undead.project(cfg, git, pid, owners).Branches[branch.Name] = dead
 				}
 			}
 
-			if response.CurrentPage >= response.TotalPages {
+			if nextPage == 0 {
 				break
 			}
-			branches_opts.Page = response.NextPage
+			branches_opts.Page = nextPage
 		}
 	}
 	return undead
 }
 
+// author validates true mail of the commit author and registers the author
+// in results if needed. Known mails are cached in trueMail.
+func (undead deadResults) author(cfg config, trueMail map[string]string, commit *gitlab.Commit) string {
+	var name string
+	var mail string
+
+	if mail, found := trueMail[commit.AuthorEmail]; found {
+		return mail
+	}
+
+	// Validate true mail
+	if ldapCheck(cfg, commit.AuthorEmail) {
+		name = commit.AuthorName
+		mail = commit.AuthorEmail
+	} else {
+		var rcptUsers []string
+
+		rcptUser := strings.Split(commit.AuthorEmail, "@")
+		rcptUsers = append(rcptUsers, rcptUser[0])
+		rcptEmails := ldapMail(cfg, rcptUsers)
+
+		if len(rcptEmails) > 0 {
+			name = commit.AuthorName
+			mail = rcptEmails[0]
+		} else {
+			rcptEmails := ldapMail(cfg, []string{commit.AuthorName})
+			if len(rcptEmails) > 0 {
+				name = commit.AuthorName
+				mail = rcptEmails[0]
+			} else {
+				name = "Unidentified"
+				mail = "unidentified@any.local"
+				log.Printf("Unidentified author: %v - %v",
+					commit.AuthorName, commit.AuthorEmail)
+			}
+		}
+	}
+
+	trueMail[commit.AuthorEmail] = mail
+
+	if _, found := undead.Authors[mail]; !found {
+		undead.Authors[mail] = deadAuthor{
+			Name:     name,
+			Branches: make(map[int][]string),
+			Merged:   make(map[int][]string),
+			Stalled:  make(map[int][]stalledMR),
+		}
+	}
+
+	return mail
+}
+
+// project fills in data for the project once and returns it
+func (undead deadResults) project(cfg config, git gitClient, pid int, owners []string) deadProject {
+	if project, found := undead.Projects[pid]; found {
+		return project
+	}
+
+	var prj_name string
+	var prj_url string
+
+	prj, err := git.GetProject(pid)
+	if err != nil {
+		prj_name = fmt.Sprintf("%v", pid)
+		prj_url = cfg.Endpoints.GitLab
+		log.Printf("Failed to get project info: %v", err)
+	} else {
+		prj_name = prj.NameWithNamespace
+		prj_url = prj.WebURL
+	}
+
+	undead.Projects[pid] = deadProject{
+		Branches: make(map[string]deadBranch),
+		Merged:   make(map[string]deadBranch),
+		Archived: make(map[string]string),
+		Owners:   owners,
+		URL:      prj_url,
+		Name:     prj_name,
+	}
+
+	return undead.Projects[pid]
+}
+
+// pendingReviewers returns members of teams which still owe votes for MR
+// who haven't voted yet
+func pendingReviewers(cfg config, git gitClient, project *Project, pid int, mr *gitlab.MergeRequest) map[string][]string {
+	reviewers := make(map[string][]string)
+
+	request, err := checkRequest(cfg, git, project, pid, mr)
+	if err != nil {
+		log.Printf("Failed to check votes of %v@%v: %v", mr.IID, pid, err)
+		return reviewers
+	}
+
+	teams := project.forBranch(mr.TargetBranch).Teams
+	for team := range request.Votes.Missing {
+		var voters []string
+		for _, voter := range request.Votes.Voters[team] {
+			voters = append(voters, strings.ToLower(voter))
+		}
+		for _, user := range teams[team] {
+			if !contains(voters, strings.ToLower(user)) {
+				reviewers[team] = append(reviewers[team], user)
+			}
+		}
+	}
+
+	return reviewers
+}
+
+// listOpenRequests returns open MRs of the project by source branch
+func listOpenRequests(git gitClient, pid int) (map[string][]*gitlab.MergeRequest, error) {
+	opened := make(map[string][]*gitlab.MergeRequest)
+
+	mrs_opts := &gitlab.ListProjectMergeRequestsOptions{
+		State: gitlab.String("opened"),
+		ListOptions: gitlab.ListOptions{
+			PerPage: 100,
+			Page:    1,
+		},
+	}
+
+	for {
+		mrs, nextPage, err := git.ListMergeRequests(pid, mrs_opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, mr := range mrs {
+			// Branches of forks are not ours
+			if mr.SourceProjectID != 0 && mr.SourceProjectID != pid {
+				continue
+			}
+			opened[mr.SourceBranch] = append(opened[mr.SourceBranch], mr)
+		}
+
+		if nextPage == 0 {
+			return opened, nil
+		}
+		mrs_opts.Page = nextPage
+	}
+}
+
+// hasLabel reports if any of MRs has the label
+func hasLabel(mrs []*gitlab.MergeRequest, label string) bool {
+	for _, mr := range mrs {
+		if contains(mr.Labels, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergedAt reports if the branch is merged into default branch or its tip
+// was merged by an MR, e.g. with squash. The latest merge of the tip by MR
+// is returned, tip commit date if the branch was merged without MR.
+func mergedAt(git gitClient, pid int, branch *gitlab.Branch) (time.Time, bool) {
+	var merged time.Time
+	found := branch.Merged
+
+	mrs_opts := &gitlab.ListProjectMergeRequestsOptions{
+		State:        gitlab.String("merged"),
+		SourceBranch: gitlab.String(branch.Name),
+	}
+	mrs, _, err := git.ListMergeRequests(pid, mrs_opts)
+	if err != nil {
+		log.Printf("Failed to list Merge Requests for %v@%v: %v", branch.Name, pid, err)
+		return merged, false
+	}
+	for _, mr := range mrs {
+		if mr.SHA != branch.Commit.ID {
+			continue
+		}
+		found = true
+		if mr.MergedAt != nil && mr.MergedAt.After(merged) {
+			merged = *mr.MergedAt
+		}
+	}
+
+	if found && merged.IsZero() {
+		merged = *branch.Commit.AuthoredDate
+	}
+
+	return merged, found
+}
+
+// wipeMerged deletes merged branches found by detectDead. Branches which
+// failed to be deleted are dropped from results.
+func wipeMerged(cfg config, git gitClient, undead deadResults) map[int][]string {
+	wiped := make(map[int][]string)
+	failed := make(map[int][]string)
+
+	for pid, project := range undead.Projects {
+		for name := range project.Merged {
+			if cfg.Branches.DryRun {
+				log.Printf("Dry run, merged branch would be deleted: %v@%v", name, pid)
+				wiped[pid] = append(wiped[pid], name)
+				continue
+			}
+
+			if err := git.DeleteBranch(pid, name); err != nil {
+				log.Printf("Failed to delete merged branch %v@%v: %v", name, pid, err)
+				failed[pid] = append(failed[pid], name)
+				continue
+			}
+
+			log.Printf("Merged branch deleted: %v@%v", name, pid)
+			wiped[pid] = append(wiped[pid], name)
+		}
+		sort.Strings(wiped[pid])
+	}
+
+	for pid, names := range failed {
+		for _, name := range names {
+			delete(undead.Projects[pid].Merged, name)
+		}
+		for mail, author := range undead.Authors {
+			var left []string
+			for _, name := range author.Merged[pid] {
+				if !contains(names, name) {
+					left = append(left, name)
+				}
+			}
+
+			if len(left) > 0 {
+				author.Merged[pid] = left
+			} else {
+				delete(author.Merged, pid)
+			}
+			if author.empty() {
+				delete(undead.Authors, mail)
+			}
+		}
+	}
+
+	return wiped
+}
+
+// tier returns the notification tier for the age of the last update
+func (tiers branchTiers) tier(age time.Duration) string {
+	days := int(age.Hours()) / 24
+
+	switch {
+	case tiers.Delete > 0 && days >= tiers.Delete:
+		return "delete"
+	case tiers.Final > 0 && days >= tiers.Final:
+		return "final"
+	case days >= tiers.Warn:
+		return "warn"
+	}
+	return ""
+}
+
+// wipeDead archives branches in the delete tier with a tag and deletes
+// them. It returns names of the wiped branches.
+func wipeDead(cfg config, git gitClient, undead deadResults) map[int][]string {
+	wiped := make(map[int][]string)
+
+	for pid, project := range undead.Projects {
+		for name, branch := range project.Branches {
+			if branch.Tier != "delete" {
+				continue
+			}
+
+			if cfg.Branches.DryRun {
+				log.Printf("Dry run, branch would be deleted: %v@%v", name, pid)
+				wiped[pid] = append(wiped[pid], name)
+				continue
+			}
+
+			// Tag already made for the same commit by a failed run is reused
+			tag := archiveTag(name, branch.Commit)
+			if err := git.CreateTag(pid, tag, branch.Commit); err != nil &&
+				!strings.Contains(err.Error(), "already exists") {
+				log.Printf("Failed to archive branch %v@%v: %v", name, pid, err)
+				continue
+			}
+
+			if err := git.DeleteBranch(pid, name); err != nil {
+				log.Printf("Failed to delete branch %v@%v: %v", name, pid, err)
+				continue
+			}
+
+			log.Printf("Branch deleted: %v@%v archived as %v", name, pid, tag)
+			wiped[pid] = append(wiped[pid], name)
+			if project.Archived == nil {
+				project.Archived = make(map[string]string)
+				undead.Projects[pid] = project
+			}
+			project.Archived[name] = tag
+		}
+		sort.Strings(wiped[pid])
+	}
+
+	return wiped
+}
+
+// archiveTag names the tag keeping a deleted branch, the short commit makes
+// it unique for branches of the same name deleted before
+func archiveTag(name string, commit string) string {
+	if len(commit) > 8 {
+		commit = commit[:8]
+	}
+	return fmt.Sprintf("archive/%v-%v", name, commit)
+}
+
+// forgetBranches drops branches from results so authors are not told about
+// branches which do not exist anymore
+func forgetBranches(undead deadResults, gone map[int][]string) {
+	for pid, names := range gone {
+		if project, found := undead.Projects[pid]; found {
+			for _, name := range names {
+				delete(project.Branches, name)
+			}
+		}
+
+		for mail, author := range undead.Authors {
+			var left []string
+			for _, name := range author.Branches[pid] {
+				if !contains(names, name) {
+					left = append(left, name)
+				}
+			}
+
+			if len(left) > 0 {
+				author.Branches[pid] = left
+			} else {
+				delete(author.Branches, pid)
+			}
+			if author.empty() {
+				delete(undead.Authors, mail)
+			}
+		}
+	}
+}
+
 func deadAuthorTemplate(dAuthor deadAuthor) (string, error) {
+	return renderTemplate("templates/dead-branches-author.gohtml", dAuthor)
+}
+
+// wipedOwners is the data for the summary of wiped branches
+type wipedOwners struct {
+	Project  deadProject
+	Branches []string
+	Merged   []string
+	DryRun   bool
+}
+
+func wipedOwnersTemplate(data wipedOwners) (string, error) {
+	return renderTemplate("templates/wiped-branches-owners.gohtml", data)
+}
+
+func renderTemplate(path string, data interface{}) (string, error) {
 	var buffer bytes.Buffer
 	var output string
 
-	tmpl := template.Must(template.ParseFiles("templates/dead-branches-author.gohtml"))
-	err := tmpl.Execute(&buffer, dAuthor)
+	tmpl := template.Must(template.ParseFiles(path))
+	err := tmpl.Execute(&buffer, data)
 	if err != nil {
 		return output, err
 	}
@@ -573,18 +1150,178 @@ func deadAuthorTemplate(dAuthor deadAuthor) (string, error) {
 	return output, nil
 }
 
-func notifyReviewers(git *gitlab.Client, reviewers map[string][]string, pid int, mid int) error {
-	msg := "Notifying reviewers:"
-	for _, team := range reviewers {
-		for _, user := range team {
-			msg = fmt.Sprintf("%v @%v", msg, user)
+const (
+	reminderPrefix   = "Reminder:"
+	escalationPrefix = "Escalated:"
+)
+
+// remindStalled re-pings teams which still owe votes for open MRs without
+// activity for RemindAfterHours and escalates to project owners by email
+// after EscalateAfterHours. Bot notes on MR tell when it was done last.
+func remindStalled(cfg config, git gitClient, MRProjects map[int]MrProject) {
+	now := time.Now()
+
+	for pid, project := range MRProjects {
+		settings, found := cfg.Projects[pid]
+		if !found || settings.RemindAfterHours <= 0 {
+			continue
+		}
+		remind := time.Duration(settings.RemindAfterHours) * time.Hour
+		escalate := time.Duration(settings.EscalateAfterHours) * time.Hour
+
+		for mid, mr := range project.MR {
+			var reminded time.Time
+			var escalated time.Time
+
+			// Disliked MRs and drafts wait for the author, not for reviewers
+			if len(mr.Votes.Missing) == 0 || mr.Awards.Dislike || mr.Draft {
+				continue
+			}
+
+			notes, err := git.ListNotes(pid, mid)
+			if err != nil {
+				log.Printf("Failed to list notes for %v@%v: %v", mid, pid, err)
+				continue
+			}
+
+			activity := mr.Activity
+			for _, note := range notes {
+				if note.CreatedAt == nil {
+					continue
+				}
+				created := *note.CreatedAt
+
+				if strings.ToLower(note.Author.Username) == git.Username() {
+					if strings.HasPrefix(note.Body, reminderPrefix) && created.After(reminded) {
+						reminded = created
+					}
+					if strings.HasPrefix(note.Body, escalationPrefix) && created.After(escalated) {
+						escalated = created
+					}
+					continue
+				}
+
+				if created.After(activity) {
+					activity = created
+				}
+			}
+
+			idle := now.Sub(activity)
+
+			if escalate > 0 && idle >= escalate && escalated.Before(activity) {
+				log.Printf("Stalled MR escalated: %v@%v", mid, pid)
+
+				var owners []string
+				for _, team := range settings.Teams {
+					owners = append(owners, team...)
+				}
+
+				subj := fmt.Sprintf("MR %v is stalled", mid)
+				msg := fmt.Sprintf(
+					"<p><a href='%v'>Merge Request #%v</a> %v has no activity for %v hours.</p>"+
+						"<p>%v</p>",
+					mr.Path, mid, template.HTMLEscapeString(mr.Name), int(idle.Hours()),
+					template.HTMLEscapeString(missingVotesMessage(settings.Teams, mr.Votes, false)))
+				if err := mailSend(cfg, ldapMail(cfg, owners), subj, msg); err != nil {
+					log.Printf("Failed to send mail to owners: %v", err)
+					continue
+				}
+
+				note := fmt.Sprintf("%v no activity for %v hours, project owners were notified.",
+					escalationPrefix, int(idle.Hours()))
+				if err := git.CreateNote(pid, mid, note); err != nil {
+					log.Printf("Failed to post escalation message for %v@%v: %v", mid, pid, err)
+				}
+				continue
+			}
+
+			if idle >= remind && now.Sub(reminded) >= remind {
+				log.Printf("Stalled MR reminded: %v@%v", mid, pid)
+
+				note := fmt.Sprintf("%v no activity for %v hours. %v", reminderPrefix, int(idle.Hours()),
+					missingVotesMessage(settings.Teams, mr.Votes, true))
+				if err := git.CreateNote(pid, mid, note); err != nil {
+					log.Printf("Failed to post reminder message for %v@%v: %v", mid, pid, err)
+				}
+			}
+		}
+	}
+}
+
+// missingVotesMessage tells how many votes each team still owes. Members
+// who have not voted yet are @-mentioned if mention is set.
+func missingVotesMessage(teams map[string][]string, votes mrVotes, mention bool) string {
+	var names []string
+	var parts []string
+
+	for team := range votes.Missing {
+		names = append(names, team)
+	}
+	sort.Strings(names)
+
+	for _, team := range names {
+		var voters []string
+		for _, voter := range votes.Voters[team] {
+			voters = append(voters, strings.ToLower(voter))
+		}
+
+		part := fmt.Sprintf("%v needs %v more vote", team, votes.Missing[team])
+		if votes.Missing[team] > 1 {
+			part += "s"
+		}
+		if mention {
+			part += ":"
+			for _, user := range teams[team] {
+				if !contains(voters, strings.ToLower(user)) {
+					part = fmt.Sprintf("%v @%v", part, user)
+				}
+			}
+		}
+		parts = append(parts, part)
+	}
+
+	return fmt.Sprintf("Waiting for votes: %v.", strings.Join(parts, "; "))
+}
+
+// notifyInvalidated tells that likes given before the latest push are reset
+func notifyInvalidated(git gitClient, reviewers map[string][]string, votes mrVotes, pid int, mid int) error {
+	msg := "New commits were pushed, approvals given before them no longer count."
+	if len(votes.Missing) > 0 {
+		msg = fmt.Sprintf("%v %v", msg, missingVotesMessage(reviewers, votes, true))
+	}
+
+	return git.CreateNote(pid, mid, msg)
+}
+
+// notifyUndrafted pings reviewers of MRs which got NotReady award as drafts
+// once they are not drafts anymore. It needs the store to tell them apart.
+func notifyUndrafted(cfg config, git gitClient, MRProjects map[int]MrProject) {
+	if history == nil {
+		return
+	}
+
+	for pid, project := range MRProjects {
+		for mid, mr := range project.MR {
+			if mr.Draft || mr.ready() || mr.Awards.NotReady == 0 || history.isNotified(pid, mid, "notready") {
+				continue
+			}
+
+			if err := notifyReviewers(git, cfg.Projects[pid].Teams, mr.Votes, pid, mid); err != nil {
+				log.Printf("Failed to post notification message for %v@%v: %v", mid, pid, err)
+			} else if err := history.setNotified(pid, mid, "notready"); err != nil {
+				log.Printf("Failed to record notification for %v@%v: %v", mid, pid, err)
+			}
 		}
 	}
+}
 
-	noteOpts := gitlab.CreateMergeRequestNoteOptions{
-		Body: &msg,
+// notifyReviewers mentions members of teams which still owe votes
+func notifyReviewers(git gitClient, reviewers map[string][]string, votes mrVotes, pid int, mid int) error {
+	if len(votes.Missing) == 0 {
+		return nil
 	}
-	_, _, err := git.Notes.CreateMergeRequestNote(pid, mid, &noteOpts)
 
-	return err
+	msg := fmt.Sprintf("Notifying reviewers. %v", missingVotesMessage(reviewers, votes, true))
+
+	return git.CreateNote(pid, mid, msg)
 }
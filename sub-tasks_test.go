@@ -0,0 +1,1004 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+func testConfig() config {
+	var cfg config
+
+	cfg.Credentials.User = "ward"
+	cfg.Endpoints.DC.Host = "127.0.0.1"
+	cfg.Awards.Like = "thumbsup"
+	cfg.Awards.Dislike = "thumbsdown"
+	cfg.Awards.Ready = "heavy_check_mark"
+	cfg.Awards.NotReady = "x"
+	cfg.Awards.NonCompliant = "poop"
+
+	return cfg
+}
+
+func testMR(iid int, state string, target string) *gitlab.MergeRequest {
+	return &gitlab.MergeRequest{
+		IID:          iid,
+		State:        state,
+		TargetBranch: target,
+		SourceBranch: fmt.Sprintf("feature-%v", iid),
+		WebURL:       fmt.Sprintf("https://git.example.com/mr/%v", iid),
+		Author:       &gitlab.BasicUser{Username: "author"},
+	}
+}
+
+type testAward struct {
+	user string
+	name string
+}
+
+func TestCheckRequest(t *testing.T) {
+	oneTeam := map[string][]string{"Backend": {"alice", "bob", "carol"}}
+	twoTeams := map[string][]string{"Backend": {"alice", "bob"}, "Frontend": {"carol", "dave"}}
+
+	tests := []struct {
+		name        string
+		teams       map[string][]string
+		votes       int
+		teamVotes   map[string]int
+		policies    []branchPolicy
+		awards      []testAward
+		wantLike    bool
+		wantDislike bool
+		wantMissing map[string]int
+	}{
+		{
+			name:        "single team needs two likes",
+			teams:       oneTeam,
+			awards:      []testAward{{"alice", "thumbsup"}},
+			wantMissing: map[string]int{"Backend": 1},
+		},
+		{
+			name:        "single team with two likes",
+			teams:       oneTeam,
+			awards:      []testAward{{"alice", "thumbsup"}, {"bob", "thumbsup"}},
+			wantLike:    true,
+			wantMissing: map[string]int{},
+		},
+		{
+			name:        "author like is ignored",
+			teams:       map[string][]string{"Backend": {"alice", "author"}},
+			awards:      []testAward{{"alice", "thumbsup"}, {"author", "thumbsup"}},
+			wantMissing: map[string]int{"Backend": 1},
+		},
+		{
+			name:        "outsider like is ignored",
+			teams:       oneTeam,
+			awards:      []testAward{{"alice", "thumbsup"}, {"eve", "thumbsup"}},
+			wantMissing: map[string]int{"Backend": 1},
+		},
+		{
+			name:        "username case is ignored",
+			teams:       oneTeam,
+			awards:      []testAward{{"Alice", "thumbsup"}, {"BOB", "thumbsup"}},
+			wantLike:    true,
+			wantMissing: map[string]int{},
+		},
+		{
+			name:        "multiple teams need a like each",
+			teams:       twoTeams,
+			awards:      []testAward{{"alice", "thumbsup"}, {"bob", "thumbsup"}},
+			wantMissing: map[string]int{"Frontend": 1},
+		},
+		{
+			name:        "multiple teams with a like each",
+			teams:       twoTeams,
+			awards:      []testAward{{"alice", "thumbsup"}, {"carol", "thumbsup"}},
+			wantLike:    true,
+			wantMissing: map[string]int{},
+		},
+		{
+			name:        "project votes override default",
+			teams:       oneTeam,
+			votes:       3,
+			awards:      []testAward{{"alice", "thumbsup"}, {"bob", "thumbsup"}},
+			wantMissing: map[string]int{"Backend": 1},
+		},
+		{
+			name:        "team votes override project votes",
+			teams:       twoTeams,
+			votes:       2,
+			teamVotes:   map[string]int{"Frontend": 1},
+			awards:      []testAward{{"alice", "thumbsup"}, {"carol", "thumbsup"}},
+			wantMissing: map[string]int{"Backend": 1},
+		},
+		{
+			name:        "branch policy narrows teams",
+			teams:       twoTeams,
+			policies:    []branchPolicy{{Branch: "release/*", Teams: []string{"Frontend"}}, {Branch: "master", Teams: []string{"Backend"}}},
+			awards:      []testAward{{"alice", "thumbsup"}},
+			wantMissing: map[string]int{"Backend": 1},
+		},
+		{
+			name:        "branch policy overrides votes",
+			teams:       oneTeam,
+			teamVotes:   map[string]int{"Backend": 3},
+			policies:    []branchPolicy{{Branch: "/^(master|main)$/", Votes: 1}},
+			awards:      []testAward{{"alice", "thumbsup"}},
+			wantLike:    true,
+			wantMissing: map[string]int{},
+		},
+		{
+			name:        "dislike is reported",
+			teams:       oneTeam,
+			awards:      []testAward{{"alice", "thumbsup"}, {"bob", "thumbsup"}, {"eve", "thumbsdown"}},
+			wantLike:    true,
+			wantDislike: true,
+			wantMissing: map[string]int{},
+		},
+		{
+			name:        "author dislike is ignored",
+			teams:       oneTeam,
+			awards:      []testAward{{"alice", "thumbsup"}, {"bob", "thumbsup"}, {"author", "thumbsdown"}},
+			wantLike:    true,
+			wantMissing: map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			git := newFakeGit()
+			project := &Project{Teams: tt.teams, TeamVotes: tt.teamVotes, Votes: tt.votes, Policies: tt.policies}
+			mr := testMR(1, "opened", "master")
+
+			for _, award := range tt.awards {
+				git.addAward(1, 1, award.user, award.name)
+			}
+
+			got, err := checkRequest(cfg, git, project, 1, mr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Awards.Like != tt.wantLike {
+				t.Errorf("Like = %v, want %v", got.Awards.Like, tt.wantLike)
+			}
+			if got.Awards.Dislike != tt.wantDislike {
+				t.Errorf("Dislike = %v, want %v", got.Awards.Dislike, tt.wantDislike)
+			}
+			if !reflect.DeepEqual(got.Votes.Missing, tt.wantMissing) {
+				t.Errorf("Missing = %v, want %v", got.Votes.Missing, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestCheckRequestServiceAwards(t *testing.T) {
+	cfg := testConfig()
+	git := newFakeGit()
+	project := &Project{Teams: map[string][]string{"Backend": {"alice"}}}
+	mr := testMR(1, "opened", "master")
+
+	ready := git.addAward(1, 1, "ward", "heavy_check_mark")
+	notReady := git.addAward(1, 1, "ward", "x")
+	git.addAward(1, 1, "alice", "heavy_check_mark")
+
+	got, err := checkRequest(cfg, git, project, 1, mr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Awards.Ready != ready.ID {
+		t.Errorf("Ready = %v, want %v", got.Awards.Ready, ready.ID)
+	}
+	if got.Awards.NotReady != notReady.ID {
+		t.Errorf("NotReady = %v, want %v", got.Awards.NotReady, notReady.ID)
+	}
+}
+
+func TestCheckRequestResetOnPush(t *testing.T) {
+	cfg := testConfig()
+	git := newFakeGit()
+	mr := testMR(1, "opened", "master")
+	pushed := time.Now().Add(-time.Hour)
+
+	before := pushed.Add(-time.Hour)
+	after := pushed.Add(time.Minute)
+	git.addAward(1, 1, "alice", "thumbsup").CreatedAt = &before
+	git.addAward(1, 1, "bob", "thumbsup").CreatedAt = &after
+	git.versions[1] = map[int][]*gitlab.MergeRequestDiffVersion{1: {{ID: 2, CreatedAt: &pushed}}}
+
+	tests := []struct {
+		name      string
+		reset     bool
+		wantLike  bool
+		wantStale []string
+	}{
+		{"likes are kept by default", false, true, nil},
+		{"likes before push are reset", true, false, []string{"alice"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := &Project{Teams: map[string][]string{"Backend": {"alice", "bob"}}, ResetVotesOnPush: tt.reset}
+
+			got, err := checkRequest(cfg, git, project, 1, mr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Awards.Like != tt.wantLike {
+				t.Errorf("Like = %v, want %v", got.Awards.Like, tt.wantLike)
+			}
+			if !reflect.DeepEqual(got.Votes.Stale, tt.wantStale) {
+				t.Errorf("Stale = %v, want %v", got.Votes.Stale, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestCheckRequestResetOnPushApprovals(t *testing.T) {
+	cfg := testConfig()
+	cfg.Approvals.Sources = []string{"emoji", "approvals"}
+	git := newFakeGit()
+	mr := testMR(1, "opened", "master")
+	pushed := time.Now().Add(-time.Hour)
+	project := &Project{Teams: map[string][]string{"Backend": {"alice", "bob", "carol"}}, ResetVotesOnPush: true}
+
+	before := pushed.Add(-time.Hour)
+	git.addAward(1, 1, "alice", "thumbsup").CreatedAt = &before
+	git.approvers[1] = map[int][]string{1: {"alice", "bob"}}
+	git.addNote(1, 1, "alice", "approved this merge request", before).System = true
+	git.addNote(1, 1, "bob", "approved this merge request", before).System = true
+	git.approvers[1][1] = append(git.approvers[1][1], "carol")
+	git.versions[1] = map[int][]*gitlab.MergeRequestDiffVersion{1: {{ID: 2, CreatedAt: &pushed}}}
+
+	got, err := checkRequest(cfg, git, project, 1, mr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Awards.Like {
+		t.Errorf("Like = true, want false")
+	}
+	if want := []string{"alice", "bob", "carol"}; !reflect.DeepEqual(got.Votes.Stale, want) {
+		t.Errorf("Stale = %v, want %v", got.Votes.Stale, want)
+	}
+}
+
+// actionNames shortens actions to sorted "award:state" for comparison
+func actionNames(actions []mrAction) []string {
+	names := []string{}
+	for _, action := range actions {
+		names = append(names, fmt.Sprintf("%v:%v", action.Award, action.State))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func testRequest(like bool, dislike bool, ready int, notReady int, nc int) map[int]MrProject {
+	var mr MergeRequest
+
+	mr.Awards.Like = like
+	mr.Awards.Dislike = dislike
+	mr.Awards.Ready = ready
+	mr.Awards.NotReady = notReady
+	mr.Awards.NonCompliant = nc
+
+	return map[int]MrProject{1: {MR: map[int]MergeRequest{1: mr}}}
+}
+
+func TestEvalOpenedRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		mrs  map[int]MrProject
+		want []string
+	}{
+		{"approved", testRequest(true, false, 0, 0, 0), []string{"ready:true"}},
+		{"approved and marked", testRequest(true, false, 1, 0, 0), []string{}},
+		{"approved after rejection", testRequest(true, false, 0, 2, 0), []string{"notready:false", "ready:true"}},
+		{"rejected", testRequest(false, false, 0, 0, 0), []string{"notready:true"}},
+		{"rejected and marked", testRequest(false, false, 0, 2, 0), []string{}},
+		{"rejected after approval", testRequest(false, false, 1, 0, 0), []string{"notready:true", "ready:false"}},
+		{"disliked", testRequest(true, true, 1, 0, 0), []string{"notready:true", "ready:false"}},
+		{"reopened", testRequest(true, false, 1, 0, 3), []string{"nc:false"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := actionNames(evalOpenedRequests(tt.mrs))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("actions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalMergedRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		mrs  map[int]MrProject
+		want []string
+	}{
+		{"approved", testRequest(true, false, 0, 0, 0), []string{}},
+		{"approved and marked", testRequest(true, false, 1, 0, 0), []string{"ready:false"}},
+		{"rejected", testRequest(false, false, 0, 2, 0), []string{"nc:true", "notready:false"}},
+		{"rejected and flagged", testRequest(false, false, 0, 0, 3), []string{}},
+		{"disliked", testRequest(true, true, 0, 0, 0), []string{"nc:true"}},
+		{"approved after flag", testRequest(true, false, 0, 0, 3), []string{"nc:false"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := actionNames(evalMergedRequests(tt.mrs))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("actions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPrjRequests(t *testing.T) {
+	cfg := testConfig()
+	git := newFakeGit()
+	projects := map[int]*Project{1: {Teams: map[string][]string{"Backend": {"alice"}}}}
+
+	git.protected[1] = []string{"master"}
+	for i := 1; i <= 150; i++ {
+		git.mrs[1] = append(git.mrs[1], testMR(i, "opened", "master"))
+	}
+	git.mrs[1] = append(git.mrs[1], testMR(151, "opened", "feature"))
+	git.mrs[1] = append(git.mrs[1], testMR(152, "merged", "master"))
+
+	mrs, err := checkPrjRequests(cfg, git, projects, "opened", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(mrs[1].MR); got != 150 {
+		t.Errorf("got %v MRs, want 150", got)
+	}
+	if _, found := mrs[1].MR[151]; found {
+		t.Errorf("MR to not protected branch was checked")
+	}
+
+	cfg.Limits.MergeRequests = 120
+	mrs, err = checkPrjRequests(cfg, git, projects, "opened", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(mrs[1].MR); got != 120 {
+		t.Errorf("got %v MRs with limit, want 120", got)
+	}
+}
+
+func TestCheckPrjRequestsWatermark(t *testing.T) {
+	cfg := testConfig()
+	git := newFakeGit()
+	projects := map[int]*Project{1: {Teams: map[string][]string{"Backend": {"alice"}}}}
+	mark := time.Now().Add(-time.Hour)
+
+	git.protected[1] = []string{"master"}
+	old := testMR(1, "merged", "master")
+	old.UpdatedAt = gitlab.Time(mark.Add(-time.Hour))
+	recent := testMR(2, "merged", "master")
+	recent.UpdatedAt = gitlab.Time(mark.Add(time.Minute))
+	git.mrs[1] = []*gitlab.MergeRequest{old, recent}
+
+	mrs, err := checkPrjRequests(cfg, git, projects, "merged", map[int]time.Time{1: mark})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := mrs[1].MR[1]; found {
+		t.Errorf("MR merged before watermark was checked")
+	}
+	if _, found := mrs[1].MR[2]; !found {
+		t.Errorf("MR merged after watermark was not checked")
+	}
+}
+
+func TestCheckPrjRequestsLimitWatermark(t *testing.T) {
+	cfg := testConfig()
+	cfg.Limits.MergeRequests = 2
+	git := newFakeGit()
+	projects := map[int]*Project{1: {Teams: map[string][]string{"Backend": {"alice"}}}}
+	start := time.Now().Add(-time.Hour)
+
+	git.protected[1] = []string{"master"}
+	for i := 1; i <= 3; i++ {
+		mr := testMR(i, "merged", "master")
+		mr.UpdatedAt = gitlab.Time(start.Add(time.Duration(i) * time.Minute))
+		git.mrs[1] = append(git.mrs[1], mr)
+	}
+
+	mrs, err := checkPrjRequests(cfg, git, projects, "merged", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Add(2 * time.Minute); !mrs[1].Updated.Equal(want) {
+		t.Errorf("Updated = %v, want %v", mrs[1].Updated, want)
+	}
+}
+
+func TestSeedWatermarks(t *testing.T) {
+	saved := mergedWatermark
+	defer func() { mergedWatermark = saved }()
+
+	cfg := testConfig()
+	git := newFakeGit()
+	projects := map[int]*Project{
+		1: {Teams: map[string][]string{"Backend": {"alice"}}},
+		2: {Teams: map[string][]string{"Backend": {"alice"}}},
+	}
+	now := time.Now()
+	mark := now.Add(-48 * time.Hour)
+	mergedWatermark = map[int]time.Time{1: mark}
+
+	git.protected[1] = []string{"master"}
+	git.protected[2] = []string{"master"}
+	for pid := 1; pid <= 2; pid++ {
+		old := testMR(1, "merged", "master")
+		old.UpdatedAt = gitlab.Time(now.Add(-24 * time.Hour))
+		git.mrs[pid] = []*gitlab.MergeRequest{old}
+	}
+
+	seedWatermarks(projects, now)
+	if !mergedWatermark[1].Equal(mark) || !mergedWatermark[2].Equal(now) {
+		t.Fatalf("watermarks = %v", mergedWatermark)
+	}
+
+	mrs, err := checkPrjRequests(cfg, git, projects, "merged", mergedWatermark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mrs[1].MR) != 1 {
+		t.Errorf("MR merged after saved watermark was not checked")
+	}
+	// New project doesn't audit merges made before it was added
+	if actions := evalMergedRequests(map[int]MrProject{2: mrs[2]}); len(mrs[2].MR) != 0 || len(actions) != 0 {
+		t.Errorf("old merges of new project are checked: %v, %v", mrs[2].MR, actions)
+	}
+}
+
+func TestAdvanceWatermarks(t *testing.T) {
+	saved := mergedWatermark
+	defer func() { mergedWatermark = saved }()
+
+	mark := time.Now().Add(-time.Hour)
+	updated := time.Now()
+	mergedWatermark = map[int]time.Time{1: mark, 2: mark, 3: mark}
+
+	advanceWatermarks(map[int]MrProject{
+		1: {Updated: updated},
+		2: {Updated: updated},
+		3: {},
+	}, map[int]bool{2: true})
+
+	want := map[int]time.Time{1: updated, 2: mark, 3: mark}
+	if !reflect.DeepEqual(mergedWatermark, want) {
+		t.Errorf("watermarks = %v, want %v", mergedWatermark, want)
+	}
+}
+
+func TestProcessMRFailed(t *testing.T) {
+	cfg := testConfig()
+	cfg.Projects = map[int]*Project{1: {Teams: map[string][]string{"Backend": {"alice"}}}}
+	git := newFakeGit()
+	git.awardErr = fmt.Errorf("403 Forbidden")
+
+	failed := processMR(cfg, git, []mrAction{{Pid: 1, Mid: 1, Award: "ready", State: true}})
+	if !failed[1] {
+		t.Errorf("failed = %v, want project 1", failed)
+	}
+}
+
+func TestProcessMR(t *testing.T) {
+	useStore(t)
+	cfg := testConfig()
+	cfg.Projects = map[int]*Project{1: {Teams: map[string][]string{"Backend": {"alice", "bob"}}}}
+	git := newFakeGit()
+
+	stale := git.addAward(1, 2, "ward", "heavy_check_mark")
+	pushed := git.addAward(1, 3, "ward", "heavy_check_mark")
+
+	processMR(cfg, git, []mrAction{
+		{Pid: 1, Mid: 1, Award: "notready", State: true, Votes: mrVotes{Missing: map[string]int{"Backend": 1}}},
+		{Pid: 1, Mid: 2, Aid: stale.ID, Award: "ready", State: false},
+		{Pid: 1, Mid: 3, Aid: pushed.ID, Award: "ready", State: false,
+			Votes: mrVotes{Missing: map[string]int{"Backend": 2}, Stale: []string{"alice"}}},
+		{Pid: 1, Mid: 3, Award: "notready", State: true,
+			Votes: mrVotes{Missing: map[string]int{"Backend": 2}, Stale: []string{"alice"}}},
+	})
+
+	if awards := git.awards[1][1]; len(awards) != 1 || awards[0].Name != "x" {
+		t.Errorf("NotReady award was not created: %v", awards)
+	}
+	if notes := git.notes[1][1]; len(notes) != 1 {
+		t.Errorf("reviewers were not notified: %v", notes)
+	}
+	if awards := git.awards[1][2]; len(awards) != 0 {
+		t.Errorf("Ready award was not removed: %v", awards)
+	}
+	if notes := git.notes[1][2]; len(notes) != 0 {
+		t.Errorf("unexpected notes: %v", notes)
+	}
+
+	want := "New commits were pushed, approvals given before them no longer count. " +
+		"Waiting for votes: Backend needs 2 more votes: @alice @bob."
+	if notes := git.notes[1][3]; len(notes) != 1 || notes[0].Body != want {
+		t.Errorf("invalidation was not noted once: %v", notes)
+	}
+	if !history.isNotified(1, 3, "notready") {
+		t.Errorf("reviewers mentioned by invalidation note would be notified again")
+	}
+}
+
+func TestDetectDead(t *testing.T) {
+	cfg := testConfig()
+	cfg.Projects = map[int]*Project{1: {Teams: map[string][]string{"Backend": {"alice"}}}}
+	git := newFakeGit()
+	now := time.Now()
+
+	branch := func(name string, age time.Duration, protected bool) *gitlab.Branch {
+		return &gitlab.Branch{
+			Name:      name,
+			Protected: protected,
+			Commit: &gitlab.Commit{
+				AuthorName:   "Alice",
+				AuthorEmail:  "alice@example.com",
+				AuthoredDate: gitlab.Time(now.Add(-age)),
+			},
+		}
+	}
+
+	day := 24 * time.Hour
+	git.projects[1] = &gitlab.Project{NameWithNamespace: "group / project", WebURL: "https://git.example.com/group/project"}
+	git.branches[1] = []*gitlab.Branch{
+		branch("master", 100*day, true),
+		branch("fresh", 6*day, false),
+		branch("stale", 7*day+time.Hour, false),
+		branch("ancient", 40*day, false),
+	}
+	for i := 0; i < 15; i++ {
+		git.branches[1] = append(git.branches[1], branch(fmt.Sprintf("paged-%v", i), 10*day, false))
+	}
+
+	undead := detectDead(cfg, git)
+
+	prj, found := undead.Projects[1]
+	if !found {
+		t.Fatalf("project was not reported")
+	}
+	if prj.Name != "group / project" {
+		t.Errorf("Name = %v", prj.Name)
+	}
+
+	tests := []struct {
+		branch string
+		found  bool
+		age    int
+	}{
+		{"master", false, 0},
+		{"fresh", false, 0},
+		{"stale", true, 7},
+		{"ancient", true, 40},
+		{"paged-14", true, 10},
+	}
+	for _, tt := range tests {
+		got, found := prj.Branches[tt.branch]
+		if found != tt.found {
+			t.Errorf("%v: found = %v, want %v", tt.branch, found, tt.found)
+			continue
+		}
+		if found && got.Age != tt.age {
+			t.Errorf("%v: Age = %v, want %v", tt.branch, got.Age, tt.age)
+		}
+	}
+
+	author, found := undead.Authors["unidentified@any.local"]
+	if !found {
+		t.Fatalf("author was not reported: %v", undead.Authors)
+	}
+	if got := len(author.Branches[1]); got != 17 {
+		t.Errorf("author has %v branches, want 17", got)
+	}
+}
+
+func TestWipeDead(t *testing.T) {
+	undead := func() deadResults {
+		return deadResults{
+			Projects: map[int]deadProject{1: {Branches: map[string]deadBranch{
+				"young": {Age: 10, Commit: "a1", Tier: "warn"},
+				"old":   {Age: 40, Commit: "b2", Tier: "delete"},
+				"taken": {Age: 50, Commit: "c3", Tier: "delete"},
+				"again": {Age: 50, Commit: "d4", Tier: "delete"},
+			}}},
+			Authors: map[string]deadAuthor{
+				"alice@example.com": {Branches: map[int][]string{1: {"young", "old"}}},
+				"bob@example.com":   {Branches: map[int][]string{1: {"taken", "again"}}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		dryRun      bool
+		want        []string
+		wantDeleted []string
+	}{
+		{"deletes old branches", false, []string{"again", "old", "taken"}, []string{"again", "old", "taken"}},
+		{"dry run", true, []string{"again", "old", "taken"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Branches.DryRun = tt.dryRun
+			git := newFakeGit()
+			for _, name := range []string{"young", "old", "taken", "again"} {
+				git.branches[1] = append(git.branches[1], &gitlab.Branch{Name: name})
+			}
+			// Branch of the same name archived before, and this commit
+			// archived by a run which failed to delete the branch
+			git.tags[1] = map[string]string{"archive/taken": "0", "archive/again-d4": "d4"}
+
+			results := undead()
+			wiped := wipeDead(cfg, git, results)
+			if !reflect.DeepEqual(wiped[1], tt.want) {
+				t.Errorf("wiped = %v, want %v", wiped[1], tt.want)
+			}
+			sort.Strings(git.deleted[1])
+			if !reflect.DeepEqual(git.deleted[1], tt.wantDeleted) {
+				t.Errorf("deleted = %v, want %v", git.deleted[1], tt.wantDeleted)
+			}
+			if len(tt.wantDeleted) > 0 {
+				want := map[string]string{"archive/taken": "0", "archive/again-d4": "d4", "archive/old-b2": "b2", "archive/taken-c3": "c3"}
+				if !reflect.DeepEqual(git.tags[1], want) {
+					t.Errorf("tags = %v, want %v", git.tags[1], want)
+				}
+				if tag := results.Projects[1].Archived["taken"]; tag != "archive/taken-c3" {
+					t.Errorf("archived as %q", tag)
+				}
+			}
+		})
+	}
+}
+
+func TestForgetBranches(t *testing.T) {
+	undead := deadResults{
+		Projects: map[int]deadProject{1: {Branches: map[string]deadBranch{"young": {}, "old": {}}}},
+		Authors: map[string]deadAuthor{
+			"alice@example.com": {Branches: map[int][]string{1: {"young", "old"}}},
+			"bob@example.com":   {Branches: map[int][]string{1: {"old"}}},
+		},
+	}
+
+	forgetBranches(undead, map[int][]string{1: {"old"}})
+
+	if _, found := undead.Projects[1].Branches["old"]; found {
+		t.Errorf("branch is still in project")
+	}
+	if got := undead.Authors["alice@example.com"].Branches[1]; !reflect.DeepEqual(got, []string{"young"}) {
+		t.Errorf("alice branches = %v", got)
+	}
+	if _, found := undead.Authors["bob@example.com"]; found {
+		t.Errorf("author without branches is still reported")
+	}
+}
+
+func TestDetectDeadMerged(t *testing.T) {
+	cfg := testConfig()
+	cfg.Projects = map[int]*Project{1: {
+		DeleteMergedAfterDays: 3,
+		KeepBranches:          []string{"release/*"},
+	}}
+	git := newFakeGit()
+	now := time.Now()
+
+	branch := func(name string, sha string, age time.Duration, merged bool) *gitlab.Branch {
+		return &gitlab.Branch{
+			Name:   name,
+			Merged: merged,
+			Commit: &gitlab.Commit{
+				ID:           sha,
+				AuthorName:   "Alice",
+				AuthorEmail:  "alice@example.com",
+				AuthoredDate: gitlab.Time(now.Add(-age)),
+			},
+		}
+	}
+
+	day := 24 * time.Hour
+	git.branches[1] = []*gitlab.Branch{
+		branch("merged", "a1", 4*day, true),
+		branch("merged-recently", "a2", 2*day, true),
+		branch("squashed", "a3", 4*day, false),
+		branch("updated", "a4", 4*day, false),
+		branch("release/1.0", "a5", 4*day, true),
+		branch("merged-today", "a6", 4*day, true),
+	}
+	squashed := testMR(1, "merged", "master")
+	squashed.SourceBranch = "squashed"
+	squashed.SHA = "a3"
+	squashed.MergedAt = gitlab.Time(now.Add(-3 * day))
+	updated := testMR(2, "merged", "master")
+	updated.SourceBranch = "updated"
+	updated.SHA = "b4"
+	// Old commit merged recently keeps its grace period
+	today := testMR(3, "merged", "master")
+	today.SourceBranch = "merged-today"
+	today.SHA = "a6"
+	today.MergedAt = gitlab.Time(now.Add(-time.Hour))
+	git.mrs[1] = []*gitlab.MergeRequest{squashed, updated, today}
+
+	undead := detectDead(cfg, git)
+
+	var got []string
+	for name := range undead.Projects[1].Merged {
+		got = append(got, name)
+	}
+	sort.Strings(got)
+	if want := []string{"merged", "squashed"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("merged = %v, want %v", got, want)
+	}
+
+	wiped := wipeMerged(cfg, git, undead)
+	if want := []string{"merged", "squashed"}; !reflect.DeepEqual(wiped[1], want) {
+		t.Errorf("wiped = %v, want %v", wiped[1], want)
+	}
+	if got := len(git.branches[1]); got != 4 {
+		t.Errorf("%v branches left, want 4", got)
+	}
+}
+
+func TestBranchTiers(t *testing.T) {
+	cfg := testConfig()
+	cfg.Branches.FinalAfterDays = 28
+	cfg.Branches.DeleteAfterDays = 35
+	cfg.Projects = map[int]*Project{
+		1: {},
+		2: {WarnAfterDays: 14, DeleteAfterDays: 60},
+	}
+
+	day := 24 * time.Hour
+	tests := []struct {
+		pid  int
+		age  time.Duration
+		want string
+	}{
+		{1, 6 * day, ""},
+		{1, 7 * day, "warn"},
+		{1, 28 * day, "final"},
+		{1, 35 * day, "delete"},
+		{2, 7 * day, ""},
+		{2, 14 * day, "warn"},
+		{2, 35 * day, "final"},
+		{2, 60 * day, "delete"},
+		{3, 40 * day, "delete"},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.tiers(tt.pid).tier(tt.age); got != tt.want {
+			t.Errorf("project %v, age %v: tier = %q, want %q", tt.pid, tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestDeadAuthorTemplate(t *testing.T) {
+	deleteAt := time.Date(2020, 11, 30, 0, 0, 0, 0, time.UTC)
+	author := deadAuthor{
+		Branches: map[int][]string{1: {"warned", "final"}},
+		Projects: map[int]deadProject{1: {
+			Name: "group / project",
+			URL:  "https://git.example.com/group/project",
+			Branches: map[string]deadBranch{
+				"warned": {Age: 15, Tier: "warn", DeleteAt: deleteAt},
+				"final":  {Age: 30, Tier: "final", DeleteAt: deleteAt},
+			},
+		}},
+		KeepLabel: "keep-branch",
+	}
+
+	msg, err := deadAuthorTemplate(author)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"warned</a> has no updates for 15 days. It will be deleted after 2020-11-30.",
+		"final</a> has no updates for 30 days. <b>Final warning</b>: it will be deleted after 2020-11-30.",
+		"labelled <code>keep-branch</code>",
+		"<code>KeepBranches</code>",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message has no %q:\n%v", want, msg)
+		}
+	}
+	if strings.Contains(msg, "Protected") {
+		t.Errorf("message still suggests protecting the branch:\n%v", msg)
+	}
+}
+
+func TestDetectDeadExempt(t *testing.T) {
+	cfg := testConfig()
+	cfg.Projects = map[int]*Project{1: {KeepBranches: []string{"release/*", "/^hotfix-[0-9]+$/"}}}
+	git := newFakeGit()
+	updated := gitlab.Time(time.Now().Add(-30 * 24 * time.Hour))
+
+	for _, name := range []string{"release/1.0", "hotfix-12", "hotfix-login", "labelled", "unlabelled"} {
+		git.branches[1] = append(git.branches[1], &gitlab.Branch{
+			Name:   name,
+			Commit: &gitlab.Commit{AuthorEmail: "alice@example.com", AuthoredDate: updated},
+		})
+	}
+	labelled := testMR(1, "opened", "master")
+	labelled.SourceBranch = "labelled"
+	labelled.Labels = gitlab.Labels{"keep-branch"}
+	unlabelled := testMR(2, "opened", "master")
+	unlabelled.SourceBranch = "unlabelled"
+	unlabelled.Labels = gitlab.Labels{"bug"}
+	git.mrs[1] = []*gitlab.MergeRequest{labelled, unlabelled}
+
+	undead := detectDead(cfg, git)
+
+	var got []string
+	for name := range undead.Projects[1].Branches {
+		got = append(got, name)
+	}
+	sort.Strings(got)
+	if want := []string{"hotfix-login"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dead = %v, want %v", got, want)
+	}
+
+	// Branch of open MR is reported as stalled
+	stalled := undead.Authors["unidentified@any.local"].Stalled[1]
+	if len(stalled) != 1 || stalled[0].Branch != "unlabelled" || stalled[0].URL != unlabelled.WebURL {
+		t.Errorf("stalled = %v", stalled)
+	}
+}
+
+func TestPendingReviewers(t *testing.T) {
+	cfg := testConfig()
+	git := newFakeGit()
+	project := &Project{
+		Teams: map[string][]string{"Backend": {"alice", "bob"}, "Frontend": {"carol", "dave"}, "QA": {"erin"}},
+		Votes: 1,
+		Paths: []pathRule{{Pattern: "*", Teams: []string{"Backend", "Frontend"}}},
+	}
+	mr := testMR(1, "opened", "master")
+	git.changes[1] = map[int][]string{1: {"main.go"}}
+	git.addAward(1, 1, "Carol", "thumbsup")
+
+	got := pendingReviewers(cfg, git, project, 1, mr)
+	want := map[string][]string{"Backend": {"alice", "bob"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reviewers = %v, want %v", got, want)
+	}
+}
+
+func TestDeadAuthorTemplateStalled(t *testing.T) {
+	author := deadAuthor{
+		Stalled: map[int][]stalledMR{1: {{Branch: "feature", Title: "Add feature", URL: "https://git.example.com/mr/1", Age: 9,
+			Reviewers: map[string][]string{"Backend": {"alice", "bob"}}}}},
+		Projects: map[int]deadProject{1: {
+			Name: "group / project",
+			URL:  "https://git.example.com/group/project",
+		}},
+	}
+
+	msg, err := deadAuthorTemplate(author)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<a href="https://git.example.com/mr/1">Add feature</a> from feature has no updates for 9 days. Waiting for votes of: Backend (@alice, @bob);`
+	if !strings.Contains(msg, want) {
+		t.Errorf("message has no %q:\n%v", want, msg)
+	}
+	if strings.Contains(msg, "dead branches") {
+		t.Errorf("message reports dead branches:\n%v", msg)
+	}
+}
+
+func TestRemindStalled(t *testing.T) {
+	now := time.Now()
+	hours := func(n int) time.Time { return now.Add(-time.Duration(n) * time.Hour) }
+
+	tests := []struct {
+		name     string
+		activity time.Time
+		missing  map[string]int
+		dislike  bool
+		notes    []testNote
+		want     string
+	}{
+		{"active", hours(2), map[string]int{"Frontend": 1}, false, nil, ""},
+		{"approved", hours(30), map[string]int{}, false, nil, ""},
+		{"disliked", hours(30), map[string]int{"Frontend": 1}, true, nil, ""},
+		{"idle", hours(30), map[string]int{"Frontend": 1}, false, nil,
+			"Reminder: no activity for 30 hours. Waiting for votes: Frontend needs 1 more vote: @carol @dave."},
+		{"commented", hours(30), map[string]int{"Frontend": 1}, false,
+			[]testNote{{"alice", "Looks fine", hours(3)}}, ""},
+		{"reminded", hours(30), map[string]int{"Frontend": 1}, false,
+			[]testNote{{"ward", "Reminder: no activity", hours(6)}}, ""},
+		{"reminded long ago", hours(60), map[string]int{"Backend": 1, "Frontend": 2}, false,
+			[]testNote{{"ward", "Reminder: no activity", hours(36)}},
+			"Reminder: no activity for 60 hours. Waiting for votes: Backend needs 1 more vote: @alice @bob; Frontend needs 2 more votes: @carol @dave."},
+		{"escalated", hours(100), map[string]int{"Frontend": 1}, false,
+			[]testNote{{"ward", "Escalated: no activity", hours(20)}, {"ward", "Reminder: no activity", hours(30)}},
+			"Reminder: no activity for 100 hours. Waiting for votes: Frontend needs 1 more vote: @carol @dave."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Projects = map[int]*Project{1: {
+				Teams:              map[string][]string{"Backend": {"alice", "bob"}, "Frontend": {"carol", "dave"}},
+				RemindAfterHours:   24,
+				EscalateAfterHours: 72,
+			}}
+			git := newFakeGit()
+			for _, note := range tt.notes {
+				git.addNote(1, 1, note.user, note.body, note.created)
+			}
+
+			var mr MergeRequest
+			mr.Activity = tt.activity
+			mr.Votes.Missing = tt.missing
+			mr.Awards.Dislike = tt.dislike
+
+			remindStalled(cfg, git, map[int]MrProject{1: {MR: map[int]MergeRequest{1: mr}}})
+
+			var got string
+			notes := git.notes[1][1]
+			if len(notes) > len(tt.notes) {
+				got = notes[len(notes)-1].Body
+			}
+			if got != tt.want {
+				t.Errorf("note = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type testNote struct {
+	user    string
+	body    string
+	created time.Time
+}
+
+func TestNotifyReviewers(t *testing.T) {
+	teams := map[string][]string{"Backend": {"alice", "bob", "carol"}, "Frontend": {"dave", "erin"}, "QA": {"frank"}}
+
+	tests := []struct {
+		name  string
+		votes mrVotes
+		want  string
+	}{
+		{"nobody owes votes", mrVotes{Missing: map[string]int{}}, ""},
+		{
+			"only missing teams and members",
+			mrVotes{
+				Voters:  map[string][]string{"Backend": {"Alice"}, "QA": {"frank"}},
+				Missing: map[string]int{"Backend": 1, "Frontend": 2},
+			},
+			"Notifying reviewers. Waiting for votes: Backend needs 1 more vote: @bob @carol; Frontend needs 2 more votes: @dave @erin.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			git := newFakeGit()
+
+			if err := notifyReviewers(git, teams, tt.votes, 1, 1); err != nil {
+				t.Fatal(err)
+			}
+
+			var got string
+			if notes := git.notes[1][1]; len(notes) > 0 {
+				got = notes[0].Body
+			}
+			if got != tt.want {
+				t.Errorf("note = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
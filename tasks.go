@@ -2,15 +2,40 @@ package main
 
 import (
 	"log"
+	"sync"
+	"time"
 )
 
+// mrMutex serializes MR processing between the scheduler and webhooks
+var mrMutex sync.Mutex
+
+// mergedWatermark keeps updated_at of the last audited merged MR per
+// project, it is loaded from the store on start
+var mergedWatermark = make(map[int]time.Time)
+
 func detectDeadBrunches(cfg config) {
-	undead := detectDead(cfg)
+	git, err := newGitClient(cfg)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	cfg = cfg.resolveTeams(git)
+
+	undead := detectDead(cfg, git)
+
+	wiped := wipeDead(cfg, git, undead)
+	if !cfg.Branches.DryRun {
+		forgetBranches(undead, wiped)
+	}
+	merged := wipeMerged(cfg, git, undead)
+
 	for rcpt, v := range undead.Authors {
 		if rcpt == "unidentified@any.local" {
 			continue
 		}
 		v.Projects = undead.Projects
+		v.DryRun = cfg.Branches.DryRun
+		v.KeepLabel = cfg.keepLabel()
 		msg, err := deadAuthorTemplate(v)
 		if err != nil {
 			log.Printf("Templating error: %v", err)
@@ -22,16 +47,54 @@ func detectDeadBrunches(cfg config) {
 			log.Printf("Failed to send mail: %v", err)
 		}
 	}
+
+	for pid, project := range undead.Projects {
+		var data wipedOwners
+
+		if len(wiped[pid]) == 0 && len(merged[pid]) == 0 {
+			continue
+		}
+
+		data.Project = project
+		data.Branches = wiped[pid]
+		data.Merged = merged[pid]
+		data.DryRun = cfg.Branches.DryRun
+
+		msg, err := wipedOwnersTemplate(data)
+		if err != nil {
+			log.Printf("Templating error: %v", err)
+			return
+		}
+
+		subj := "Dead branches deleted"
+		if cfg.Branches.DryRun {
+			subj = "Dead branches to be deleted"
+		}
+		if err := mailSend(cfg, ldapMail(cfg, data.Project.Owners), subj, msg); err != nil {
+			log.Printf("Failed to send mail to owners: %v", err)
+		}
+	}
 }
 
 func detectMR(cfg config) []mrAction {
-	mrsOpened, err := checkPrjRequests(cfg, cfg.Projects, "opened")
+	mrMutex.Lock()
+	defer mrMutex.Unlock()
+
+	git, err := newGitClient(cfg)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	cfg = cfg.resolveTeams(git)
+
+	mrsOpened, err := checkPrjRequests(cfg, git, cfg.Projects, "opened", nil)
 	if err != nil {
 		log.Println(err)
 	}
 	actionsOpened := evalOpenedRequests(mrsOpened)
 
-	mrsMerged, err := checkPrjRequests(cfg, cfg.Projects, "merged")
+	seedWatermarks(cfg.Projects, time.Now())
+	mrsMerged, err := checkPrjRequests(cfg, git, cfg.Projects, "merged", mergedWatermark)
 	if err != nil {
 		log.Println(err)
 	}
@@ -39,7 +102,98 @@ func detectMR(cfg config) []mrAction {
 
 	actions := append(actionsOpened, actionsMerged...)
 
-	processMR(cfg, actions)
+	failed := processMR(cfg, git, actions)
+	notifyUndrafted(cfg, git, mrsOpened)
+	reportStatus(cfg, git, mrsOpened)
+
+	advanceWatermarks(mrsMerged, failed)
+
+	return actions
+}
+
+// seedWatermarks starts audit of projects without watermark from now, so
+// merges made before the project was added are not flagged
+func seedWatermarks(projects map[int]*Project, now time.Time) {
+	if mergedWatermark == nil {
+		mergedWatermark = make(map[int]time.Time)
+	}
+
+	for pid := range projects {
+		if mark, found := mergedWatermark[pid]; found && !mark.IsZero() {
+			continue
+		}
+
+		mergedWatermark[pid] = now
+		if err := history.setWatermark(pid, now); err != nil {
+			log.Printf("Failed to save watermark for %v: %v", pid, err)
+		}
+	}
+}
+
+// advanceWatermarks moves watermarks to the last merged MR checked. Projects
+// missing from the results or with failed actions will be rechecked.
+func advanceWatermarks(mrs map[int]MrProject, failed map[int]bool) {
+	for pid, project := range mrs {
+		if failed[pid] || project.Updated.IsZero() {
+			continue
+		}
+
+		mergedWatermark[pid] = project.Updated
+		if err := history.setWatermark(pid, project.Updated); err != nil {
+			log.Printf("Failed to save watermark for %v: %v", pid, err)
+		}
+	}
+}
+
+func detectStalledMR(cfg config) {
+	git, err := newGitClient(cfg)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	cfg = cfg.resolveTeams(git)
+
+	mrs, err := checkPrjRequests(cfg, git, cfg.Projects, "opened", nil)
+	if err != nil {
+		log.Println(err)
+	}
+
+	remindStalled(cfg, git, mrs)
+}
+
+func detectHookMR(cfg config, pid int, mid int) []mrAction {
+	var actions []mrAction
+
+	mrMutex.Lock()
+	defer mrMutex.Unlock()
+
+	git, err := newGitClient(cfg)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	cfg = cfg.resolveTeams(git)
+
+	mrs, state, err := checkPrjRequest(cfg, git, pid, mid)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	switch state {
+	case "opened":
+		actions = evalOpenedRequests(mrs)
+	case "merged":
+		actions = evalMergedRequests(mrs)
+	default:
+		return nil
+	}
+
+	processMR(cfg, git, actions)
+	if state == "opened" {
+		notifyUndrafted(cfg, git, mrs)
+		reportStatus(cfg, git, mrs)
+	}
 
 	return actions
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// ldapPrefix marks team groups which are AD group DNs
+const ldapPrefix = "ldap:"
+
+// groupCache keeps members of GitLab and LDAP groups between runs
+var groupCache = struct {
+	sync.Mutex
+	entries map[string]groupEntry
+}{entries: make(map[string]groupEntry)}
+
+type groupEntry struct {
+	Members []groupMember
+	Fetched time.Time
+}
+
+// groupMember is an active member of a group, Access is zero for LDAP
+type groupMember struct {
+	Username string
+	Access   gitlab.AccessLevelValue
+}
+
+// ldapGroupMembers expands AD group, replaced in tests
+var ldapGroupMembers = ldapGroup
+
+// groupMembers returns cached members of the group refreshing them once
+// the cache expires. Stale members are used if the source fails.
+func groupMembers(cfg config, git gitClient, path string) ([]groupMember, error) {
+	var members []groupMember
+
+	groupCache.Lock()
+	defer groupCache.Unlock()
+
+	entry, found := groupCache.entries[path]
+	if found && time.Since(entry.Fetched) < cfg.groupsCache() {
+		return entry.Members, nil
+	}
+
+	err := func() error {
+		if strings.HasPrefix(path, ldapPrefix) {
+			users, err := ldapGroupMembers(cfg, strings.TrimPrefix(path, ldapPrefix))
+			for _, user := range users {
+				members = append(members, groupMember{Username: user})
+			}
+			return err
+		}
+
+		users, err := git.ListGroupMembers(path)
+		for _, user := range users {
+			if user.State == "" || user.State == "active" {
+				members = append(members, groupMember{Username: user.Username, Access: user.AccessLevel})
+			}
+		}
+		return err
+	}()
+	if err != nil {
+		if found {
+			log.Printf("Failed to refresh members of %v, using cached: %v", path, err)
+			return entry.Members, nil
+		}
+		return nil, err
+	}
+
+	groupCache.entries[path] = groupEntry{Members: members, Fetched: time.Now()}
+	return members, nil
+}
+
+// resolveTeams returns config with members of group teams added to Teams
+// of every project. Configured members are kept as well.
+func (c config) resolveTeams(git gitClient) config {
+	resolved := c
+	resolved.Projects = make(map[int]*Project)
+
+	for pid, project := range c.Projects {
+		if len(project.TeamGroups) == 0 {
+			resolved.Projects[pid] = project
+			continue
+		}
+
+		scoped := *project
+		scoped.Teams = make(map[string][]string)
+		for name, members := range project.Teams {
+			scoped.Teams[name] = append([]string(nil), members...)
+		}
+
+		for name, group := range project.TeamGroups {
+			members, err := groupMembers(c, git, group.Path)
+			if err != nil {
+				log.Printf("Failed to get members of %v for team %v: %v", group.Path, name, err)
+				continue
+			}
+
+			for _, member := range members {
+				user := strings.ToLower(member.Username)
+				if member.Access < group.MinAccess || contains(scoped.Teams[name], user) {
+					continue
+				}
+				scoped.Teams[name] = append(scoped.Teams[name], user)
+			}
+		}
+
+		resolved.Projects[pid] = &scoped
+	}
+
+	return resolved
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/yaml.v3"
+)
+
+func TestResolveTeams(t *testing.T) {
+	groupCache.entries = make(map[string]groupEntry)
+
+	data := `
+Projects:
+  1:
+    Teams:
+      Backend:
+        Group: org/backend
+        MinAccess: Developer
+        Members:
+          - alice
+      Frontend:
+        - carol
+`
+	var cfg config
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	git := newFakeGit()
+	git.groups["org/backend"] = []*gitlab.GroupMember{
+		{Username: "Alice", State: "active", AccessLevel: gitlab.MaintainerPermissions},
+		{Username: "bob", State: "active", AccessLevel: gitlab.DeveloperPermissions},
+		{Username: "eve", State: "active", AccessLevel: gitlab.ReporterPermissions},
+		{Username: "mallory", State: "blocked", AccessLevel: gitlab.OwnerPermissions},
+	}
+
+	resolved := cfg.resolveTeams(git)
+
+	want := map[string][]string{"Backend": {"alice", "bob"}, "Frontend": {"carol"}}
+	got := resolved.Projects[1].Teams
+	for _, members := range got {
+		sort.Strings(members)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Teams = %v, want %v", got, want)
+	}
+	if members := cfg.Projects[1].Teams["Backend"]; !reflect.DeepEqual(members, []string{"alice"}) {
+		t.Errorf("configured members were changed: %v", members)
+	}
+
+	// Members are cached and kept when GitLab fails
+	delete(git.groups, "org/backend")
+	cfg.resolveTeams(git)
+	if git.groupCalls != 1 {
+		t.Errorf("group requests = %v, want 1", git.groupCalls)
+	}
+
+	groupCache.entries["org/backend"] = groupEntry{
+		Members: groupCache.entries["org/backend"].Members,
+		Fetched: time.Now().Add(-2 * time.Hour),
+	}
+	resolved = cfg.resolveTeams(git)
+	if git.groupCalls != 2 {
+		t.Errorf("group requests = %v, want 2", git.groupCalls)
+	}
+	if members := resolved.Projects[1].Teams["Backend"]; len(members) != 2 {
+		t.Errorf("cached members were not used: %v", members)
+	}
+}
+
+func TestTeamAccessLevel(t *testing.T) {
+	tests := []struct {
+		name  string
+		group string
+		level string
+	}{
+		{"unknown level", "org/backend", "admin"},
+		{"ldap group", "ldap:CN=Backend,DC=example,DC=com", "developer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := fmt.Sprintf("Projects:\n  1:\n    Teams:\n      Backend:\n        Group: %v\n        MinAccess: %v\n",
+				tt.group, tt.level)
+
+			var cfg config
+			if err := yaml.Unmarshal([]byte(data), &cfg); err == nil {
+				t.Error("access level was accepted")
+			}
+		})
+	}
+}
+
+func TestResolveTeamsLDAP(t *testing.T) {
+	groupCache.entries = make(map[string]groupEntry)
+	defer func() { ldapGroupMembers = ldapGroup }()
+
+	var requested string
+	ldapGroupMembers = func(cfg config, dn string) ([]string, error) {
+		requested = dn
+		return []string{"Alice", "bob"}, nil
+	}
+
+	data := `
+Projects:
+  1:
+    Teams:
+      Backend: ldap:CN=Backend,OU=Groups,DC=example,DC=com
+`
+	var cfg config
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved := cfg.resolveTeams(newFakeGit())
+
+	if want := "CN=Backend,OU=Groups,DC=example,DC=com"; requested != want {
+		t.Errorf("requested group = %q, want %q", requested, want)
+	}
+	want := []string{"alice", "bob"}
+	if got := resolved.Projects[1].Teams["Backend"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Backend = %v, want %v", got, want)
+	}
+}
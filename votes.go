@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// vote is a like or dislike of a user from any approval source. Time is
+// zero when the source doesn't tell it.
+type vote struct {
+	User string
+	Like bool
+	Time time.Time
+}
+
+// collectVotes gathers votes from configured sources. A user is counted
+// once per kind of vote, the latest known time is kept.
+func collectVotes(cfg config, git gitClient, pid int, mid int, awards []*gitlab.AwardEmoji) ([]vote, error) {
+	var votes []vote
+	seen := make(map[string]int)
+
+	add := func(v vote) {
+		key := fmt.Sprintf("%v:%v", strings.ToLower(v.User), v.Like)
+		if i, found := seen[key]; found {
+			if v.Time.After(votes[i].Time) {
+				votes[i].Time = v.Time
+			}
+			return
+		}
+		seen[key] = len(votes)
+		votes = append(votes, v)
+	}
+
+	for _, source := range cfg.approvalSources() {
+		switch source {
+		case "emoji":
+			for _, award := range awards {
+				v := vote{User: award.User.Username}
+				if award.CreatedAt != nil {
+					v.Time = *award.CreatedAt
+				}
+
+				switch award.Name {
+				case cfg.Awards.Like:
+					v.Like = true
+					add(v)
+				case cfg.Awards.Dislike:
+					add(v)
+				}
+			}
+		case "approvals":
+			approvers, err := git.ListApprovers(pid, mid)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to list MR approvals: %v", err)
+			}
+			var approved map[string]time.Time
+			if len(approvers) > 0 {
+				notes, err := git.ListNotes(pid, mid)
+				if err != nil {
+					return nil, fmt.Errorf("Failed to list MR notes: %v", err)
+				}
+				approved = approvalTimes(notes)
+			}
+			for _, user := range approvers {
+				add(vote{User: user, Like: true, Time: approved[strings.ToLower(user)]})
+			}
+		case "notes":
+			notes, err := git.ListNotes(pid, mid)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to list MR notes: %v", err)
+			}
+			commands, err := noteVotes(cfg, git.Username(), notes)
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range commands {
+				add(v)
+			}
+		}
+	}
+
+	return votes, nil
+}
+
+// approvalTimes returns time of the latest approval of every user taken
+// from system notes as approvals API doesn't tell it
+func approvalTimes(notes []*gitlab.Note) map[string]time.Time {
+	approved := make(map[string]time.Time)
+
+	for _, note := range notes {
+		if !note.System || note.CreatedAt == nil || !strings.HasPrefix(note.Body, "approved this merge request") {
+			continue
+		}
+		user := strings.ToLower(note.Author.Username)
+		if note.CreatedAt.After(approved[user]) {
+			approved[user] = *note.CreatedAt
+		}
+	}
+
+	return approved
+}
+
+// noteVotes returns the latest approve or block command of every user
+func noteVotes(cfg config, bot string, notes []*gitlab.Note) ([]vote, error) {
+	var votes []vote
+	latest := make(map[string]int)
+
+	approvePattern, blockPattern := cfg.noteCommands()
+	approve, err := regexp.Compile(approvePattern)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse approve command: %v", err)
+	}
+	block, err := regexp.Compile(blockPattern)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse block command: %v", err)
+	}
+
+	sorted := make([]*gitlab.Note, len(notes))
+	copy(sorted, notes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].CreatedAt == nil || sorted[j].CreatedAt == nil {
+			return false
+		}
+		return sorted[i].CreatedAt.Before(*sorted[j].CreatedAt)
+	})
+
+	for _, note := range sorted {
+		user := strings.ToLower(note.Author.Username)
+		if note.System || user == bot {
+			continue
+		}
+
+		v := vote{User: note.Author.Username}
+		if note.CreatedAt != nil {
+			v.Time = *note.CreatedAt
+		}
+
+		switch {
+		case block.MatchString(note.Body):
+		case approve.MatchString(note.Body):
+			v.Like = true
+		default:
+			continue
+		}
+
+		if i, found := latest[user]; found {
+			votes[i] = v
+			continue
+		}
+		latest[user] = len(votes)
+		votes = append(votes, v)
+	}
+
+	return votes, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCollectVotes(t *testing.T) {
+	now := time.Now()
+
+	git := newFakeGit()
+	git.addAward(1, 1, "alice", "thumbsup")
+	git.addAward(1, 1, "eve", "thumbsdown")
+	git.approvers[1] = map[int][]string{1: {"Alice", "bob"}}
+	git.addNote(1, 1, "carol", "/block needs tests", now.Add(-time.Hour))
+	git.addNote(1, 1, "carol", "/lgtm", now)
+	git.addNote(1, 1, "dave", "/block", now)
+	git.addNote(1, 1, "erin", "looks good /lgtm", now)
+	git.addNote(1, 1, "ward", "/lgtm", now)
+
+	tests := []struct {
+		name    string
+		sources []string
+		want    []string
+	}{
+		{"emoji by default", nil, []string{"alice:true", "eve:false"}},
+		{"approvals", []string{"approvals"}, []string{"Alice:true", "bob:true"}},
+		{"notes", []string{"notes"}, []string{"carol:true", "dave:false"}},
+		{"user is counted once", []string{"emoji", "approvals"}, []string{"alice:true", "eve:false", "bob:true"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Approvals.Sources = tt.sources
+
+			votes, err := collectVotes(cfg, git, 1, 1, git.awards[1][1])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, v := range votes {
+				got = append(got, fmt.Sprintf("%v:%v", v.User, v.Like))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("votes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRequestNoteVotes(t *testing.T) {
+	cfg := testConfig()
+	cfg.Approvals.Sources = []string{"emoji", "notes"}
+	git := newFakeGit()
+	project := &Project{Teams: map[string][]string{"Backend": {"alice", "bob"}}}
+	mr := testMR(1, "opened", "master")
+
+	git.addAward(1, 1, "alice", "thumbsup")
+	git.addNote(1, 1, "bob", "/lgtm", time.Now())
+
+	got, err := checkRequest(cfg, git, project, 1, mr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Awards.Like {
+		t.Errorf("Like = false, want true: %+v", got.Votes)
+	}
+
+	git.addNote(1, 1, "bob", "/block", time.Now().Add(time.Minute))
+
+	got, err = checkRequest(cfg, git, project, 1, mr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Awards.Like || !got.Awards.Dislike {
+		t.Errorf("Like = %v, Dislike = %v, want false, true", got.Awards.Like, got.Awards.Dislike)
+	}
+}
+
+func TestCollectVotesTime(t *testing.T) {
+	cfg := testConfig()
+	cfg.Approvals.Sources = []string{"emoji", "approvals"}
+	git := newFakeGit()
+	liked := time.Now().Add(-time.Hour)
+	approved := time.Now()
+
+	git.addAward(1, 1, "alice", "thumbsup").CreatedAt = &liked
+	git.addAward(1, 1, "bob", "thumbsup").CreatedAt = &liked
+	git.approvers[1] = map[int][]string{1: {"alice", "Bob"}}
+	git.addNote(1, 1, "bob", "approved this merge request", approved).System = true
+	git.addNote(1, 1, "alice", "approved this merge request", approved)
+
+	votes, err := collectVotes(cfg, git, 1, 1, git.awards[1][1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]time.Time)
+	for _, v := range votes {
+		got[v.User] = v.Time
+	}
+	want := map[string]time.Time{"alice": liked, "bob": approved}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("times = %v, want %v", got, want)
+	}
+}